@@ -0,0 +1,170 @@
+// Run a planner headless and print the best path as json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"image"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/brychanrobot/go-rrt-star/rrtstar"
+	"github.com/brychanrobot/go-rrt-star/viewshed"
+	"github.com/skelterjohn/geom"
+)
+
+type result struct {
+	Iterations int           `json:"iterations"`
+	Elapsed    float64       `json:"elapsed"`
+	NumNodes   uint64        `json:"nodes"`
+	Found      bool          `json:"found"`
+	Cost       float64       `json:"cost"`
+	Coverage   float64       `json:"coverage"`
+	StartPoint geom.Coord    `json:"start"`
+	EndPoint   geom.Coord    `json:"end"`
+	Path       []*geom.Coord `json:"path"`
+	SmoothPath []*geom.Coord `json:"smooth_path,omitempty"`
+}
+
+func main() {
+	numObstacles := flag.Int("obstacles", 15, "sets the number of obstacles generated")
+	usePolygons := flag.Bool("polygons", false, "generates convex polygon obstacles instead of rectangles")
+	useCircles := flag.Bool("circles", false, "generates circular obstacles instead of rectangles")
+	iterations := flag.Int("i", -1, "sets the number of iterations. -1 runs until the time budget is spent")
+	budget := flag.Duration("t", 0, "sets the wall-clock budget for sampling, e.g. 30s")
+	startWithFmt := flag.Bool("fmt", false, "seeds the tree using FMT")
+	robotRadius := flag.Float64("radius", 0, "keeps the path this far away from obstacles")
+	turningRadius := flag.Float64("dubins", 0, "steers with dubins curves for a vehicle with this turning radius")
+	bidirectional := flag.Bool("bi", false, "grows trees from both the start and the end")
+	informed := flag.Bool("informed", false, "samples only where the path can still improve once one is found")
+	useEuclidean := flag.Bool("euclidean", false, "plans the shortest path instead of the path with the least unseen area")
+	sensorRange := flag.Float64("range", 0, "limits how far the camera can see. 0 sees to the edge of the map")
+	fieldOfView := flag.Float64("fov", 0, "sets the camera's field of view in degrees, centered on the direction of travel. 0 sees all the way around")
+	visibilitySpacing := flag.Float64("grid", 10, "sets the spacing of the grid unseen area is precomputed on. 0 sweeps a viewshed for every node")
+	integrationTolerance := flag.Float64("integrate", 0, "integrates unseen area along each edge to within this tolerance instead of averaging its ends")
+	visibilityCache := flag.String("cache", "", "saves precomputed unseen area in this directory and loads it on later runs of the same map")
+	coverage := flag.Bool("coverage", false, "plans for the most area seen along the whole path instead of the least unseen area at each point")
+	coverageCells := flag.Float64("cells", 5, "sets the size of the cells coverage is counted on")
+	mapFile := flag.String("map", "", "loads the obstacles from an image instead of generating them. needs -euclidean, since nothing can be seen around an image")
+	width := flag.Int("width", 700, "sets the width of a generated map")
+	height := flag.Int("height", 700, "sets the height of a generated map")
+	smooth := flag.Bool("smooth", false, "also prints a shortcut and smoothed version of the path")
+	seed := flag.Int64("seed", 0, "seeds the random generator. defaults to the current time")
+	flag.Parse()
+
+	if *iterations < 0 && *budget <= 0 {
+		log.Fatal("either -i or -t must be set")
+	}
+	// a loaded map only has pixels, so the viewshed would see straight through its obstacles
+	if *mapFile != "" && (!*useEuclidean || *coverage) {
+		log.Fatal("-map can only be used with -euclidean and without -coverage")
+	}
+	planners := 0
+	for _, set := range []bool{*startWithFmt, *turningRadius > 0, *bidirectional, *coverage} {
+		if set {
+			planners++
+		}
+	}
+	if planners > 1 {
+		log.Fatal("only one of -fmt, -dubins, -bi and -coverage can be used")
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rand.Seed(*seed)
+
+	var obstacleImage *image.Gray
+	var obstacleRects []*geom.Rect
+	var obstaclePolygons []rrtstar.Polygon
+	var obstacleCircles []*rrtstar.Circle
+	if *mapFile != "" {
+		var err error
+		obstacleImage, err = rrtstar.LoadObstacleImage(*mapFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*width = obstacleImage.Bounds().Dx()
+		*height = obstacleImage.Bounds().Dy()
+	} else if *usePolygons {
+		obstaclePolygons, obstacleImage = rrtstar.GeneratePolygonObstacles(*width, *height, *numObstacles)
+	} else if *useCircles {
+		obstacleCircles, obstacleImage = rrtstar.GenerateCircleObstacles(*width, *height, *numObstacles)
+	} else {
+		obstacleRects, obstacleImage = rrtstar.GenerateObstacles(*width, *height, *numObstacles)
+	}
+	obstacleMap := rrtstar.NewObstacleMap(obstacleImage, obstacleRects, obstaclePolygons, obstacleCircles)
+
+	var planner rrtstar.Planner
+	config := rrtstar.DefaultPlannerConfig(*width, *height)
+	config.InformedSampling = *informed
+	config.RobotRadius = *robotRadius
+	config.Sensor = viewshed.Sensor{MaxRange: *sensorRange, FieldOfView: *fieldOfView * math.Pi / 180}
+	config.VisibilitySpacing = *visibilitySpacing
+	config.VisibilityCacheDir = *visibilityCache
+	config.IntegrationTolerance = *integrationTolerance
+	config.CoverageCellSize = *coverageCells
+	if *useEuclidean {
+		config.CostFunction = rrtstar.EuclideanCost{}
+	}
+
+	var err error
+	if *startWithFmt {
+		config.MaxSegment = 6
+		planner, err = rrtstar.NewFmtStar(obstacleMap, obstacleRects, config)
+	} else if *turningRadius > 0 {
+		config.TurningRadius = *turningRadius
+		planner, err = rrtstar.NewDubinsRrtStar(obstacleMap, obstacleRects, config)
+	} else if *bidirectional {
+		planner, err = rrtstar.NewBiRrtStar(obstacleMap, obstacleRects, config)
+	} else if *coverage {
+		planner, err = rrtstar.NewCoverageRrtStar(obstacleMap, obstacleRects, config)
+	} else {
+		planner, err = rrtstar.NewRrtStar(obstacleMap, obstacleRects, config)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	start := time.Now()
+	i := 0
+	for ; i < *iterations || *iterations < 0; i++ {
+		if *budget > 0 && time.Since(start) >= *budget {
+			break
+		}
+		planner.Sample()
+	}
+
+	cost := planner.GetBestPathCost()
+	path := planner.GetBestPath()
+	found := !math.IsInf(cost, 1)
+	if !found {
+		cost = 0
+		path = nil
+	}
+
+	out := result{
+		Iterations: i,
+		Elapsed:    time.Since(start).Seconds(),
+		NumNodes:   planner.GetNumNodes(),
+		Found:      found,
+		Cost:       cost,
+		Coverage:   planner.GetBestPathCoverage(),
+		StartPoint: *planner.GetStartPoint(),
+		EndPoint:   *planner.GetEndPoint(),
+		Path:       path,
+	}
+
+	if *smooth && found {
+		out.SmoothPath = planner.SmoothPath(planner.ShortcutPath(path), 8)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		log.Fatal(err)
+	}
+}
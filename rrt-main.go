@@ -34,6 +34,8 @@ var (
 	font             *gltext.Font
 	obstaclesTexture uint32
 	obstacleRects    []*geom.Rect
+	obstaclePolygons []rrtstar.Polygon
+	obstacleCircles  []*rrtstar.Circle
 	//planner          *rrtstar.FmtStar
 	planner    rrtstar.Planner
 	frames     []*image.NRGBA
@@ -41,6 +43,8 @@ var (
 
 	cursorX float64
 	cursorY float64
+	// the planner hands out copies of its viewshed, so the one swept from the cursor is kept here
+	cursorViewshed *viewshed.Viewshed
 
 	moveX float64
 	moveY float64
@@ -49,6 +53,11 @@ var (
 	moveEndY float64
 
 	waldos []*rrtstar.Waldo
+
+	// blocks dropped on the map while it runs, newest last
+	droppedObstacles []*geom.Rect
+
+	showSmoothPath bool
 )
 
 type Alignment uint32
@@ -277,25 +286,18 @@ func drawTree(node *rrtstar.Node, lineHue float64) {
 	drawPoint(node.Coord, 2, colorful.Hsv(float64(int(lineHue+node.CumulativeCost/12.0)%360), 1, 0.6))
 }
 
-func drawNode(node *rrtstar.Node, lineHue float64) {
-	for _, child := range node.Children {
+// drawTreeFaster draws the edges walk visits in one batch, without copying the tree
+func drawTreeFaster(walk func(visit func(parent, child *rrtstar.Node)), lineHue float64) {
+	gl.LineWidth(1)
+	gl.Begin(gl.LINES)
+
+	walk(func(node, child *rrtstar.Node) {
 		hue := int(lineHue+child.CumulativeCost/12.0) % 360
 		color := colorful.Hsv(float64(hue), 1, 0.6)
-		//drawLine(node.Point, child.Point, colorful.Hsv(float64(hue), 1, 0.6))
-		//drawTree(child, lineHue)
 		gl.Color3d(color.R, color.G, color.B)
 		gl.Vertex2d(node.Coord.X, node.Coord.Y)
 		gl.Vertex2d(child.Coord.X, child.Coord.Y)
-
-		drawNode(child, lineHue)
-	}
-}
-
-func drawTreeFaster(node *rrtstar.Node, lineHue float64) {
-	gl.LineWidth(1)
-	gl.Begin(gl.LINES)
-
-	drawNode(node, lineHue)
+	})
 
 	gl.End()
 }
@@ -373,7 +375,7 @@ func drawBackground(color colorful.Color) {
 	gl.Disable(gl.TEXTURE_2D)
 }
 
-func drawObstacles(obstacleRects []*geom.Rect, color colorful.Color) {
+func drawObstacles(obstacleRects []*geom.Rect, obstaclePolygons []rrtstar.Polygon, obstacleCircles []*rrtstar.Circle, color colorful.Color) {
 	gl.Begin(gl.QUADS)
 	gl.Color3d(color.R, color.G, color.B)
 	for _, rect := range obstacleRects {
@@ -383,16 +385,42 @@ func drawObstacles(obstacleRects []*geom.Rect, color colorful.Color) {
 		gl.Vertex2d(rect.Min.X, rect.Max.Y)
 	}
 	gl.End()
+
+	// gl.POLYGON only fills convex outlines properly, which is all the generator makes
+	for _, polygon := range obstaclePolygons {
+		gl.Begin(gl.POLYGON)
+		gl.Color3d(color.R, color.G, color.B)
+		for _, vertex := range polygon {
+			gl.Vertex2d(vertex.X, vertex.Y)
+		}
+		gl.End()
+	}
+
+	for _, circle := range obstacleCircles {
+		gl.Begin(gl.POLYGON)
+		gl.Color3d(color.R, color.G, color.B)
+		for i := 0; i < 60; i++ {
+			angle := 2 * math.Pi * float64(i) / 60
+			gl.Vertex2d(circle.X+circle.Radius*math.Cos(angle), circle.Y+circle.Radius*math.Sin(angle))
+		}
+		gl.End()
+	}
 }
 
-func drawWaldos(waldos []*rrtstar.Waldo, color colorful.Color) {
+// drawWaldos draws the waldos that shed can see in visibleColor, if there's a viewshed to look from
+func drawWaldos(waldos []*rrtstar.Waldo, color, visibleColor colorful.Color, shed *viewshed.Viewshed) {
 	for _, waldo := range waldos {
-		drawPath(append(waldo.CurrentPath, &waldo.Coord), colorful.Hsv(280, 1, 0.3), 3)
-		if waldo.CurrentWaypoint != nil {
-			drawPoint(*waldo.CurrentWaypoint, 10, colorful.Hsv(170, 1, 1))
+		position := waldo.GetPosition()
+		drawPath(append(waldo.GetCurrentPath(), &position), colorful.Hsv(280, 1, 0.3), 3)
+		if waypoint := waldo.GetCurrentWaypoint(); waypoint != nil {
+			drawPoint(*waypoint, 10, colorful.Hsv(170, 1, 1))
 		}
-		drawFloatPoint(waldo.X, waldo.Y, 30, color)
-		drawStringPoint(fmt.Sprintf("%d", waldo.Importance), waldo.Coord, Center, Center, colorful.Hsv(310, 1, 0))
+		if shed != nil && shed.IsVisible(position) {
+			drawFloatPoint(position.X, position.Y, 30, visibleColor)
+		} else {
+			drawFloatPoint(position.X, position.Y, 30, color)
+		}
+		drawStringPoint(fmt.Sprintf("%d", waldo.Importance), position, Center, Center, colorful.Hsv(310, 1, 0))
 	}
 }
 
@@ -439,27 +467,44 @@ func display(iteration uint64, showTree, showViewshed, showPath, showIterationCo
 	gl.Clear(gl.COLOR_BUFFER_BIT)
 	gl.ClearColor(0, 0, 0, 0)
 
-	drawObstacles(obstacleRects, colorful.Hsv(210, 1, 0.6))
+	drawObstacles(obstacleRects, obstaclePolygons, obstacleCircles, colorful.Hsv(210, 1, 0.6))
 
-	if showViewshed {
-		drawViewshed(planner.GetViewshed().ViewablePolygon, &planner.GetViewshed().Center, colorful.Hsv(330, 1, 1), 3)
+	var shed *viewshed.Viewshed
+	if showViewshed && cursorViewshed != nil {
+		shed = cursorViewshed
+		drawViewshed(cursorViewshed.ViewablePolygon, &cursorViewshed.Center, colorful.Hsv(330, 1, 1), 3)
 	}
 
 	if showTree {
-		drawTreeFaster(planner.GetRoot(), 250)
+		drawTreeFaster(planner.WalkTree, 250)
+		if biRrtStar, ok := planner.(*rrtstar.BiRrtStar); ok {
+			drawTreeFaster(biRrtStar.WalkGoalTree, 30)
+		}
 	}
 
-	drawWaldos(waldos, colorful.Hsv(290, 1, 1))
+	drawWaldos(waldos, colorful.Hsv(290, 1, 1), colorful.Hsv(330, 1, 1), shed)
 
 	if showPath {
 		drawPath(planner.GetBestPath(), colorful.Hsv(100, 1, 1), 3)
+		if showSmoothPath {
+			smoothPath := planner.SmoothPath(planner.ShortcutPath(planner.GetBestPath()), 8)
+			drawPath(smoothPath, colorful.Hsv(50, 1, 1), 2)
+		}
 
 		drawPoint(*planner.GetEndPoint(), 20, colorful.Hsv(60, 1, 1))
 		drawPoint(*planner.GetStartPoint(), 20, colorful.Hsv(20, 1, 1))
+		if !planner.GetIsGoalReachable() {
+			endPoint := *planner.GetEndPoint()
+			drawStringPoint("unreachable", geom.Coord{X: endPoint.X, Y: endPoint.Y + 15}, Center, Top, colorful.Hsv(60, 1, 1))
+		}
 	}
 
 	if showIterationCount {
 		drawStringPoint(fmt.Sprintf("%d", iteration), geom.Coord{X: 10, Y: 10}, Left, Top, colorful.Hsv(180, 1, 1))
+		// the coverage planner already knows its coverage, any other would have to sweep the whole path every frame
+		if coverageRrtStar, ok := planner.(*rrtstar.CoverageRrtStar); ok && len(coverageRrtStar.GetBestPath()) > 0 {
+			drawStringPoint(fmt.Sprintf("%.1f%% seen", coverageRrtStar.GetBestPathCoverage()), geom.Coord{X: 10, Y: 30}, Left, Top, colorful.Hsv(180, 1, 1))
+		}
 	}
 
 	if showViewshed {
@@ -477,6 +522,8 @@ func main() {
 	isFullscreen := flag.Bool("full", false, "the map will expand to fullscreen on the primary monitor if set")
 	isLooping := flag.Bool("loop", false, "will loop with random obstacles if set")
 	numObstacles := flag.Int("obstacles", 15, "sets the number of obstacles generated")
+	usePolygons := flag.Bool("polygons", false, "generates convex polygon obstacles instead of rectangles")
+	useCircles := flag.Bool("circles", false, "generates circular obstacles instead of rectangles")
 	monitorNum := flag.Int("monitor", 0, "sets which monitor to display on in fullscreen. default to primary")
 	iterations := flag.Int("i", -1, "sets the number of iterations. default to 1000000")
 	//iterationsPerFrame := flag.Int("if", 50, "sets the number of iterations to evaluate between frames")
@@ -484,12 +531,34 @@ func main() {
 	renderCostmap := flag.Bool("cm", false, "renders a costmap before executing")
 	showPath := flag.Bool("path", true, "shows the path and endpoints")
 	showIterationCount := flag.Bool("count", true, "shows the iteration count")
+	smoothPath := flag.Bool("smooth", false, "draws a shortcut and smoothed path alongside the best path. toggle with m")
 	showTree := flag.Bool("tree", false, "draws the tree")
 	showViewshed := flag.Bool("viewshed", false, "draws the viewshed at the mouse cursor location")
 	numWaldos := flag.Int("waldos", 0, "the number of waldos to simulate")
 	startWithFmt := flag.Bool("fmt", false, "seeds the tree using FMT")
+	robotRadius := flag.Float64("radius", 0, "keeps the path this far away from obstacles")
+	turningRadius := flag.Float64("dubins", 0, "steers with dubins curves for a vehicle with this turning radius")
+	bidirectional := flag.Bool("bi", false, "grows trees from both the start and the end")
+	informed := flag.Bool("informed", false, "samples only where the path can still improve once one is found")
+	useEuclidean := flag.Bool("euclidean", false, "plans the shortest path instead of the path with the least unseen area")
+	sensorRange := flag.Float64("range", 0, "limits how far the camera can see. 0 sees to the edge of the map")
+	fieldOfView := flag.Float64("fov", 0, "sets the camera's field of view in degrees, centered on the direction of travel. 0 sees all the way around")
+	visibilitySpacing := flag.Float64("grid", 10, "sets the spacing of the grid unseen area is precomputed on. 0 sweeps a viewshed for every node")
+	integrationTolerance := flag.Float64("integrate", 0, "integrates unseen area along each edge to within this tolerance instead of averaging its ends")
+	visibilityCache := flag.String("cache", "", "saves precomputed unseen area in this directory and loads it on later runs of the same map")
+	coverage := flag.Bool("coverage", false, "plans for the most area seen along the whole path instead of the least unseen area at each point")
+	coverageCells := flag.Float64("cells", 5, "sets the size of the cells coverage is counted on")
+	executeSpeed := flag.Float64("execute", 0, "drives the start along the best path at this many pixels per second, rerooting the tree as it goes")
+	executeRange := flag.Float64("keep", 0, "prunes branches farther than this from the vehicle while executing. 0 keeps them all")
 	flag.Parse()
 
+	// fmt* and the bidirectional planner don't grow a tree the vehicle can keep rerooting as it drives
+	if *executeSpeed > 0 && (*startWithFmt || *bidirectional) {
+		log.Fatal("-execute can't be used with -fmt or -bi")
+	}
+
+	showSmoothPath = *smoothPath
+
 	glfwErr := glfw.Init()
 	if glfwErr != nil {
 		panic(glfwErr)
@@ -539,19 +608,62 @@ func main() {
 
 		rand.Seed(time.Now().UnixNano()) // apparently golang random is deterministic by default
 		var obstacleImage *image.Gray
-		obstacleRects, obstacleImage = rrtstar.GenerateObstacles(width, height, *numObstacles)
+		if *usePolygons {
+			obstaclePolygons, obstacleImage = rrtstar.GeneratePolygonObstacles(width, height, *numObstacles)
+		} else if *useCircles {
+			obstacleCircles, obstacleImage = rrtstar.GenerateCircleObstacles(width, height, *numObstacles)
+		} else {
+			obstacleRects, obstacleImage = rrtstar.GenerateObstacles(width, height, *numObstacles)
+		}
+		obstacleMap := rrtstar.NewObstacleMap(obstacleImage, obstacleRects, obstaclePolygons, obstacleCircles)
+		config := rrtstar.DefaultPlannerConfig(width, height)
+		config.InformedSampling = *informed
+		config.RobotRadius = *robotRadius
+		config.Sensor = viewshed.Sensor{MaxRange: *sensorRange, FieldOfView: *fieldOfView * math.Pi / 180}
+		config.VisibilitySpacing = *visibilitySpacing
+		config.VisibilityCacheDir = *visibilityCache
+		config.IntegrationTolerance = *integrationTolerance
+		config.CoverageCellSize = *coverageCells
+		config.ExecutionSpeed = *executeSpeed
+		config.ExecutionBudget = 40 * time.Millisecond
+		config.ExecutionRange = *executeRange
+		if *useEuclidean {
+			config.CostFunction = rrtstar.EuclideanCost{}
+		}
+
+		var err error
 		if *startWithFmt {
-			planner = rrtstar.NewFmtStar(obstacleImage, obstacleRects, 6, width, height, nil, nil)
+			config.MaxSegment = 6
+			planner, err = rrtstar.NewFmtStar(obstacleMap, obstacleRects, config)
+		} else if *turningRadius > 0 {
+			config.TurningRadius = *turningRadius
+			planner, err = rrtstar.NewDubinsRrtStar(obstacleMap, obstacleRects, config)
+		} else if *bidirectional {
+			planner, err = rrtstar.NewBiRrtStar(obstacleMap, obstacleRects, config)
+		} else if *coverage {
+			planner, err = rrtstar.NewCoverageRrtStar(obstacleMap, obstacleRects, config)
 		} else {
-			planner = rrtstar.NewRrtStar(obstacleImage, obstacleRects, 12, width, height, nil, nil)
+			planner, err = rrtstar.NewRrtStar(obstacleMap, obstacleRects, config)
+		}
+		if err != nil {
+			log.Fatal(err)
 		}
 
 		if *renderCostmap {
 			planner.RenderUnseenCostMap("unseen.png")
 		}
 
+		executor, executing := planner.(rrtstar.Executor)
+		if *executeSpeed > 0 && !executing {
+			log.Fatalf("can't execute the path with a %T", planner)
+		}
+		executing = executing && *executeSpeed > 0
+		lastTick := time.Now()
+
 		for i := 0; i < *numWaldos; i++ {
-			waldo := rrtstar.NewWaldo(rrtstar.RandomRrt, uint32(rand.Int31n(5))+1, obstacleImage)
+			waldo := rrtstar.NewWaldo(rrtstar.RandomRrt, uint32(rand.Int31n(5))+1, obstacleMap)
+			waldo.Config.VisibilitySpacing = *visibilitySpacing
+			waldo.Config.VisibilityCacheDir = *visibilityCache
 			waldos = append(waldos, waldo)
 		}
 
@@ -561,7 +673,14 @@ func main() {
 		for i := 0; !window.ShouldClose(); i++ {
 
 			if i < *iterations || *iterations == -1 {
-				planner.Sample()
+				if executing {
+					// each tick drives the vehicle and samples for its budget instead of sampling once
+					now := time.Now()
+					executor.Execute(now.Sub(lastTick))
+					lastTick = now
+				} else {
+					planner.Sample()
+				}
 				//if i%*iterationsPerFrame == 0 {
 				if sw.Get().Seconds() > 0.050 {
 					planner.MoveStartPoint(moveX, moveY)
@@ -582,9 +701,10 @@ func main() {
 
 			if redraw {
 				//log.Println("redrawing", i)
-				if *showViewshed && (cursorX != planner.GetViewshed().Center.X || cursorY != -planner.GetViewshed().Center.Y) {
-					planner.GetViewshed().UpdateCenterLocation(cursorX, cursorY)
-					planner.GetViewshed().Sweep()
+				if *showViewshed && (cursorViewshed == nil || cursorX != cursorViewshed.Center.X || cursorY != cursorViewshed.Center.Y) {
+					cursorViewshed = planner.GetViewshed()
+					cursorViewshed.UpdateCenterLocation(cursorX, cursorY)
+					cursorViewshed.Sweep()
 				}
 
 				display(planner.GetNumNodes(), *showTree, *showViewshed, *showPath, *showIterationCount)
@@ -633,6 +753,38 @@ func onChar(w *glfw.Window, char rune) {
 	if char == 'p' {
 		//planner.Prune(30)
 	}
+	if char == 'm' {
+		showSmoothPath = !showSmoothPath
+		invalidate()
+	}
+	if char == 'o' {
+		block := &geom.Rect{Min: geom.Coord{X: cursorX - 20, Y: cursorY - 20}, Max: geom.Coord{X: cursorX + 20, Y: cursorY + 20}}
+		if err := planner.AddObstacle(block); err != nil {
+			log.Println(err)
+		} else {
+			droppedObstacles = append(droppedObstacles, block)
+			obstacleRects = append(obstacleRects[:len(obstacleRects):len(obstacleRects)], block)
+			// the cursor's viewshed is a copy from before the block was there
+			cursorViewshed = nil
+			invalidate()
+		}
+	}
+	if char == 'u' && len(droppedObstacles) > 0 {
+		block := droppedObstacles[len(droppedObstacles)-1]
+		if err := planner.RemoveObstacle(block); err != nil {
+			log.Println(err)
+		} else {
+			droppedObstacles = droppedObstacles[:len(droppedObstacles)-1]
+			for i, rect := range obstacleRects {
+				if rect == block {
+					obstacleRects = append(obstacleRects[:i:i], obstacleRects[i+1:]...)
+					break
+				}
+			}
+			cursorViewshed = nil
+			invalidate()
+		}
+	}
 }
 
 func onKey(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
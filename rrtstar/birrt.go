@@ -0,0 +1,208 @@
+package rrtstar
+
+import (
+	"math"
+
+	"github.com/dhconnelly/rtreego"
+	"github.com/skelterjohn/geom"
+)
+
+// bridge is an edge joining a node in the start tree to a node in the goal tree
+type bridge struct {
+	start *Node
+	goal  *Node
+	cost  float64
+}
+
+func (b *bridge) totalCost() float64 {
+	return b.start.CumulativeCost + b.cost + b.goal.CumulativeCost
+}
+
+// BiRrtStar grows one rrt* from the start and another from the end and joins them where they meet.
+// The goal tree is driven toward its root, so its edges and the bridges are costed the way they're driven
+type BiRrtStar struct {
+	PlannerBase
+	GoalRoot  *Node
+	goalRtree *rtreego.Rtree
+	// bridges holds the cheapest bridge found from each node of the start tree
+	bridges    map[*Node]*bridge
+	bestBridge *bridge
+	growGoal   bool
+}
+
+// NewBiRrtStar creates a new bidirectional rrt star
+func NewBiRrtStar(obstacleMap ObstacleMap, obstacleRects []*geom.Rect, config PlannerConfig) (*BiRrtStar, error) {
+	base, err := newPlannerBase(obstacleMap, obstacleRects, config)
+	if err != nil {
+		return nil, err
+	}
+
+	biRrtStar := &BiRrtStar{PlannerBase: base, goalRtree: config.newRtree(), bridges: make(map[*Node]*bridge)}
+	biRrtStar.reversedRtree = biRrtStar.goalRtree
+	biRrtStar.nodeThreshold = uint64(config.NodeDensity * float64(config.Width*config.Height))
+
+	biRrtStar.Root = &Node{parent: nil, Coord: *biRrtStar.StartPoint, CumulativeCost: 0}
+	biRrtStar.rtree.Insert(biRrtStar.Root)
+
+	biRrtStar.GoalRoot = &Node{parent: nil, Coord: *biRrtStar.EndPoint, CumulativeCost: 0}
+	biRrtStar.goalRtree.Insert(biRrtStar.GoalRoot)
+	biRrtStar.NumNodes = 2
+
+	biRrtStar.Root.UnseenArea = biRrtStar.getPointCost(biRrtStar.StartPoint)
+	biRrtStar.GoalRoot.UnseenArea = biRrtStar.getPointCost(biRrtStar.EndPoint)
+
+	return biRrtStar, nil
+}
+
+func (b *BiRrtStar) bestPathCost() float64 {
+	if b.bestBridge == nil {
+		return math.Inf(1)
+	}
+	return b.bestBridge.totalCost()
+}
+
+// connect looks for the cheapest way to join node to the other tree and keeps it as a bridge if it's
+// the cheapest from its start tree node so far. The other tree is rewired around where the bridge
+// lands, since that's where paths across it go
+func (b *BiRrtStar) connect(node *Node, isStartNode bool) {
+	otherRtree, otherRoot := b.goalRtree, b.GoalRoot
+	if !isStartNode {
+		otherRtree, otherRoot = b.rtree, b.Root
+	}
+
+	other, cost, _, _ := b.getBestNeighborIn(otherRtree, &node.Coord, b.rewireNeighborhood)
+	if other == nil {
+		return
+	}
+	b.rewireNeighbors(otherRtree, otherRoot, other, b.edgesIn(otherRtree))
+
+	candidate := &bridge{start: node, goal: other, cost: cost}
+	if !isStartNode {
+		candidate = &bridge{start: other, goal: node, cost: cost}
+	}
+	if current, ok := b.bridges[candidate.start]; !ok || candidate.totalCost() < current.totalCost() {
+		b.bridges[candidate.start] = candidate
+	}
+}
+
+// edgesIn joins the nodes of rtree with straight lines costed the way they're driven
+func (b *BiRrtStar) edgesIn(rtree *rtreego.Rtree) treeEdges {
+	edges := b.straightEdges()
+	edges.cost = func(parent, child *Node) float64 {
+		return b.getCostIn(rtree, &parent.Coord, &child.Coord)
+	}
+	return edges
+}
+
+func (b *BiRrtStar) refreshBestPath() {
+	// rewiring on either side can make any bridge the cheapest, so check them all
+	b.bestBridge = nil
+	bestCost := math.MaxFloat64
+	for _, candidate := range b.bridges {
+		if cost := candidate.totalCost(); cost < bestCost {
+			bestCost = cost
+			b.bestBridge = candidate
+		}
+	}
+
+	b.BestPath = b.BestPath[:0]
+	if b.bestBridge == nil {
+		return
+	}
+
+	// the path runs from the end to the start like the other planners
+	for currentNode := b.bestBridge.goal; currentNode != nil; currentNode = currentNode.parent {
+		b.BestPath = append(b.BestPath, &currentNode.Coord)
+	}
+	for i, j := 0, len(b.BestPath)-1; i < j; i, j = i+1, j-1 {
+		b.BestPath[i], b.BestPath[j] = b.BestPath[j], b.BestPath[i]
+	}
+	for currentNode := b.bestBridge.start; currentNode != nil; currentNode = currentNode.parent {
+		b.BestPath = append(b.BestPath, &currentNode.Coord)
+	}
+}
+
+// moveStartPoint takes the root of the start tree to the moved start without adding a node
+func (b *BiRrtStar) moveStartPoint(dx, dy float64) {
+	if dx != 0 || dy != 0 {
+		b.StartPoint.X += dx
+		b.StartPoint.Y += dy
+
+		edges := b.edgesIn(b.rtree)
+		removed := b.moveRoot(*b.StartPoint, edges)
+		b.rewireNeighbors(b.rtree, b.Root, b.Root, edges)
+		b.checkBridges(removed, b.Root)
+		b.refreshBestPath()
+	}
+}
+
+// moveEndPoint moves the root of the goal tree with the end point without adding a node
+func (b *BiRrtStar) moveEndPoint(dx, dy float64) {
+	if dx != 0 || dy != 0 {
+		b.EndPoint.X += dx
+		b.EndPoint.Y += dy
+
+		edges := b.edgesIn(b.goalRtree)
+		removed := b.relocateRoot(b.goalRtree, b.GoalRoot, *b.EndPoint, edges)
+		b.rewireNeighbors(b.goalRtree, b.GoalRoot, b.GoalRoot, edges)
+		b.checkBridges(removed, b.GoalRoot)
+		b.refreshBestPath()
+	}
+}
+
+// checkBridges drops the bridges from removed nodes and the ones from moved that an obstacle now
+// blocks, and costs the rest of the bridges from moved again
+func (b *BiRrtStar) checkBridges(removed []*Node, moved *Node) {
+	gone := make(map[*Node]bool, len(removed))
+	for _, node := range removed {
+		gone[node] = true
+	}
+	for start, candidate := range b.bridges {
+		switch {
+		case gone[candidate.start] || gone[candidate.goal]:
+			delete(b.bridges, start)
+		case candidate.start != moved && candidate.goal != moved:
+		case b.lineIntersectsObstacle(candidate.start.Coord, candidate.goal.Coord):
+			delete(b.bridges, start)
+		default:
+			candidate.cost = b.getCost(&candidate.start.Coord, &candidate.goal.Coord)
+		}
+	}
+}
+
+// nextSamplePoint samples the informed ellipse between the roots once the trees have been bridged
+// and informed sampling is on, otherwise the whole map
+func (b *BiRrtStar) nextSamplePoint() geom.Coord {
+	if b.informedSampling && b.bestBridge != nil {
+		if point, ok := b.nextInformedPoint(b.Root.Coord, b.GoalRoot.Coord, b.bestBridge.totalCost()); ok {
+			return point
+		}
+	}
+	return b.nextHaltonPoint(b.width, b.height)
+}
+
+// sample grows whichever tree is next, alternating between them
+func (b *BiRrtStar) sample() {
+	point := b.nextSamplePoint()
+
+	rtree := b.rtree
+	if b.growGoal {
+		rtree = b.goalRtree
+	}
+
+	b.IsAddingNodes = b.NumNodes < b.nodeThreshold
+	if b.IsAddingNodes {
+		if newNode := b.extendTree(rtree, point); newNode != nil {
+			b.connect(newNode, !b.growGoal)
+		}
+	} else {
+		b.rewireTree(rtree, point)
+		// the trees keep looking for cheaper ways across once they've stopped growing, or a bridge
+		// lost to an obstacle could never be replaced
+		nn := rtree.NearestNeighbor(rtreego.Point{point.X, point.Y}).(*Node)
+		b.connect(nn, !b.growGoal)
+	}
+
+	b.growGoal = !b.growGoal
+	b.refreshBestPath()
+}
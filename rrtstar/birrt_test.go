@@ -0,0 +1,66 @@
+package rrtstar
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/skelterjohn/geom"
+)
+
+// inTree reports whether node hangs from root
+func inTree(node, root *Node) bool {
+	for ; node != nil; node = node.parent {
+		if node == root {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBiRrtStarKeepsTheCheapestBridge(t *testing.T) {
+	config := DefaultPlannerConfig(200, 200)
+	config.StartPoint = &geom.Coord{X: 20, Y: 100}
+	config.EndPoint = &geom.Coord{X: 180, Y: 100}
+	config.CostFunction = EuclideanCost{}
+	bi, err := NewBiRrtStar(NewRasterObstacleMap(image.NewGray(image.Rect(0, 0, 200, 200))), nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2000; i++ {
+		bi.Sample()
+	}
+	if bi.bestBridge == nil {
+		t.Fatal("the trees never met")
+	}
+
+	for start, candidate := range bi.bridges {
+		if candidate.start != start {
+			t.Errorf("the bridge kept for %v starts at %v", start.Coord, candidate.start.Coord)
+		}
+		if !inTree(candidate.start, bi.Root) || !inTree(candidate.goal, bi.GoalRoot) {
+			t.Errorf("the bridge from %v to %v doesn't join the start tree to the goal tree", candidate.start.Coord, candidate.goal.Coord)
+		}
+		if d := math.Abs(candidate.cost - euclideanDistance(&candidate.start.Coord, &candidate.goal.Coord)); d > 1e-9 {
+			t.Errorf("the bridge from %v to %v is off by %f", candidate.start.Coord, candidate.goal.Coord, d)
+		}
+		if candidate.totalCost() < bi.bestBridge.totalCost() {
+			t.Errorf("a bridge costing %f was passed over for one costing %f", candidate.totalCost(), bi.bestBridge.totalCost())
+		}
+	}
+
+	path := bi.GetBestPath()
+	if len(path) < 2 || *path[0] != *bi.EndPoint || *path[len(path)-1] != *bi.StartPoint {
+		t.Fatalf("the best path doesn't run from the end to the start: %v", path)
+	}
+	length := 0.0
+	for i := 1; i < len(path); i++ {
+		length += euclideanDistance(path[i-1], path[i])
+	}
+	if d := math.Abs(length - bi.GetBestPathCost()); d > 1e-6 {
+		t.Errorf("the best path is %f long but costs %f", length, bi.GetBestPathCost())
+	}
+	if straight := euclideanDistance(bi.StartPoint, bi.EndPoint); length > 1.1*straight {
+		t.Errorf("the best path across an empty map is %f long, the straight line is %f", length, straight)
+	}
+}
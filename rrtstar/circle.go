@@ -0,0 +1,45 @@
+package rrtstar
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/brychanrobot/go-rrt-star/viewshed"
+	"github.com/skelterjohn/geom"
+)
+
+// Circle is a round obstacle like a tank, silo or tree
+type Circle struct {
+	geom.Coord
+	Radius float64
+}
+
+// Bounds returns the square the circle fits in
+func (c *Circle) Bounds() geom.Rect {
+	offset := geom.Coord{X: c.Radius, Y: c.Radius}
+	return geom.Rect{Min: c.Coord.Minus(offset), Max: c.Coord.Plus(offset)}
+}
+
+func (c *Circle) area() float64 {
+	return math.Pi * c.Radius * c.Radius
+}
+
+// randomCircle places a circle somewhere inside the map
+func randomCircle(width, height int) *Circle {
+	maxRadius := math.Max(math.Min(float64(width), float64(height))/8, 5)
+	radius := 5 + rand.Float64()*(maxRadius-5)
+	return &Circle{
+		Coord: geom.Coord{
+			X: radius + rand.Float64()*(float64(width)-2*radius),
+			Y: radius + rand.Float64()*(float64(height)-2*radius)},
+		Radius: radius}
+}
+
+// circleBlocks converts circles into the blocks the viewshed takes
+func circleBlocks(circles []*Circle) []*viewshed.Block {
+	blocks := make([]*viewshed.Block, len(circles))
+	for i, circle := range circles {
+		blocks[i] = viewshed.NewBlock(circle.X, circle.Y, circle.Radius)
+	}
+	return blocks
+}
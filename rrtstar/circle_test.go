@@ -0,0 +1,71 @@
+package rrtstar
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brychanrobot/go-rrt-star/viewshed"
+	"github.com/skelterjohn/geom"
+)
+
+func TestCircleCollisions(t *testing.T) {
+	circle := &Circle{Coord: geom.Coord{X: 50, Y: 50}, Radius: 10}
+	obstacleMap := NewVectorObstacleMap(100, 100, nil, nil, []*Circle{circle})
+	inflated := obstacleMap.Inflate(3)
+
+	for _, test := range []struct {
+		name        string
+		obstacleMap ObstacleMap
+		point       geom.Coord
+		blocked     bool
+	}{
+		{"inside", obstacleMap, geom.Coord{X: 55, Y: 55}, true},
+		{"in the corner of its bounds", obstacleMap, geom.Coord{X: 58, Y: 58}, false},
+		{"within the inflation", inflated, geom.Coord{X: 50, Y: 62}, true},
+		{"past the inflation", inflated, geom.Coord{X: 50, Y: 64}, false},
+	} {
+		if blocked := test.obstacleMap.PointIntersects(test.point); blocked != test.blocked {
+			t.Errorf("%s: %v should be blocked: %v", test.name, test.point, test.blocked)
+		}
+	}
+
+	for _, test := range []struct {
+		name        string
+		obstacleMap ObstacleMap
+		p1, p2      geom.Coord
+		free        bool
+	}{
+		{"through", obstacleMap, geom.Coord{X: 30, Y: 45}, geom.Coord{X: 70, Y: 55}, false},
+		{"cutting off a little", obstacleMap, geom.Coord{X: 30, Y: 41}, geom.Coord{X: 70, Y: 41}, false},
+		{"past", obstacleMap, geom.Coord{X: 30, Y: 39}, geom.Coord{X: 70, Y: 39}, true},
+		// the line would cross the circle but the segment stops short of it
+		{"stopping short", obstacleMap, geom.Coord{X: 10, Y: 50}, geom.Coord{X: 38, Y: 50}, true},
+		{"past within the inflation", inflated, geom.Coord{X: 30, Y: 38}, geom.Coord{X: 70, Y: 38}, false},
+		{"past the inflation", inflated, geom.Coord{X: 30, Y: 36}, geom.Coord{X: 70, Y: 36}, true},
+	} {
+		if free := test.obstacleMap.SegmentIsFree(test.p1, test.p2); free != test.free {
+			t.Errorf("%s: the segment from %v to %v should be free: %v", test.name, test.p1, test.p2, test.free)
+		}
+	}
+}
+
+func TestCirclesCastShadows(t *testing.T) {
+	// the circle hides a cone out to the edge of the map between the tangents from the center, less
+	// the kite between the center and the tangent points and plus the sector of the circle inside it
+	const distance, radius = 20.0, 10.0
+	shed := &viewshed.Viewshed{}
+	shed.LoadMap(100, 100, 0, nil, nil, circleBlocks([]*Circle{{Coord: geom.Coord{X: 50 + distance, Y: 50}, Radius: radius}}), nil)
+	shed.UpdateCenterLocation(50, 50)
+	shed.Sweep()
+
+	halfAngle := math.Asin(radius / distance)
+	cone := 50 * 50 * math.Tan(halfAngle)
+	kite := math.Sqrt(distance*distance-radius*radius) * radius
+	sector := radius * radius * (math.Pi - 2*halfAngle) / 2
+	hidden := cone - (kite - sector)
+
+	// the visible side of the circle is traced with chords between rays, which cut slivers off it
+	if area := viewshed.Area2DPolygon(shed.ViewablePolygon); math.Abs(area-(100*100-hidden)) > 1 {
+		t.Errorf("%f can be seen, expected %f", area, 100*100-hidden)
+	}
+}
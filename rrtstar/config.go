@@ -0,0 +1,256 @@
+package rrtstar
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/brychanrobot/go-halton"
+	"github.com/brychanrobot/go-rrt-star/viewshed"
+	"github.com/dhconnelly/rtreego"
+	"github.com/skelterjohn/geom"
+)
+
+const (
+	defaultFreeThreshold     = 50
+	defaultObstacleThreshold = 200
+)
+
+// PlannerConfig holds the tunable parameters shared by every planner
+type PlannerConfig struct {
+	Width  int
+	Height int
+
+	// StartPoint and EndPoint are chosen randomly in open space when nil
+	StartPoint *geom.Coord
+	EndPoint   *geom.Coord
+
+	// MaxSegment is the longest edge a new node may be connected with
+	MaxSegment float64
+	// RewireFactor scales MaxSegment into the neighborhood searched when rewiring
+	RewireFactor float64
+
+	// NodeDensity is how many nodes per square pixel rrt* adds before it only rewires
+	NodeDensity float64
+	// FmtSampleDensity is how many samples per square pixel fmt* seeds the map with
+	FmtSampleDensity float64
+
+	// RobotRadius inflates the obstacles for collision checking so the robot's body clears them.
+	// The viewshed still sees the obstacles as they are
+	RobotRadius float64
+
+	// InformedSampling restricts rrt* samples to the region that could improve the best path once
+	// one is found. It needs a BoundedCostFunction with a positive bound to have any effect
+	InformedSampling bool
+	// MinUnseen is the least unseen area the default UnseenAreaCost may assume anywhere on the map,
+	// which bounds the informed region. The precomputed grid's lowest point is used if it's higher
+	MinUnseen float64
+
+	// TurningRadius is the tightest turn a dubins planner may make and StartHeading is the
+	// direction the vehicle faces at the start, in radians
+	TurningRadius float64
+	StartHeading  float64
+
+	// RtreeMinChildren and RtreeMaxChildren set the branching of the spatial index
+	RtreeMinChildren int
+	RtreeMaxChildren int
+
+	// Sensor is the camera the default UnseenAreaCost and the planner's Viewshed see with. Its
+	// Heading is only used for single points, along an edge the camera faces the way it travels
+	Sensor viewshed.Sensor
+
+	// VisibilitySpacing is how far apart the default UnseenAreaCost precomputes unseen area, 0
+	// sweeps a viewshed for every point instead. Every planner on the same map shares one field,
+	// and it's saved in VisibilityCacheDir when that's set
+	VisibilitySpacing  float64
+	VisibilityCacheDir string
+
+	// IntegrationTolerance makes the default UnseenAreaCost integrate unseen area along each edge
+	// to within this much, 0 averages the two ends of the edge. Integrating sweeps every point it
+	// samples rather than reading the precomputed grid
+	IntegrationTolerance float64
+
+	// CoverageCellSize is the size of the cells coverage of the map is counted on, both by the
+	// coverage planner and for reporting how much of the map the best path sees. 0 turns both off
+	CoverageCellSize float64
+
+	// CostFunction defaults to an UnseenAreaCost when nil
+	CostFunction CostFunction
+
+	// ExecutionSpeed is how many pixels a second the vehicle drives along the best path when the
+	// planner executes it, and ExecutionBudget is how long each tick may spend sampling
+	ExecutionSpeed  float64
+	ExecutionBudget time.Duration
+	// ExecutionRange is how far from the vehicle branches are kept while executing, 0 keeps them all
+	ExecutionRange float64
+}
+
+// DefaultPlannerConfig returns the configuration the planners have always used
+func DefaultPlannerConfig(width, height int) PlannerConfig {
+	return PlannerConfig{
+		Width:             width,
+		Height:            height,
+		MaxSegment:        12,
+		RewireFactor:      6,
+		NodeDensity:       0.01,
+		FmtSampleDensity:  0.015,
+		VisibilitySpacing: 10,
+		CoverageCellSize:  5,
+		RtreeMinChildren:  25,
+		RtreeMaxChildren:  50,
+		ExecutionBudget:   20 * time.Millisecond}
+}
+
+func (c *PlannerConfig) inBounds(point *geom.Coord) bool {
+	return point.X >= 0 && point.Y >= 0 && point.X < float64(c.Width) && point.Y < float64(c.Height)
+}
+
+// Validate reports the first parameter that would keep a planner from working
+func (c *PlannerConfig) Validate() error {
+	switch {
+	case c.Width <= 0 || c.Height <= 0:
+		return fmt.Errorf("map size must be positive, got %dx%d", c.Width, c.Height)
+	case c.MaxSegment <= 0:
+		return fmt.Errorf("max segment must be positive, got %f", c.MaxSegment)
+	case c.RewireFactor <= 0:
+		return fmt.Errorf("rewire factor must be positive, got %f", c.RewireFactor)
+	case c.NodeDensity <= 0:
+		return fmt.Errorf("node density must be positive, got %f", c.NodeDensity)
+	case c.FmtSampleDensity <= 0:
+		return fmt.Errorf("fmt sample density must be positive, got %f", c.FmtSampleDensity)
+	case c.MinUnseen < 0 || c.MinUnseen > 1:
+		return fmt.Errorf("min unseen area must be between 0 and 1, got %f", c.MinUnseen)
+	case c.RobotRadius < 0:
+		return fmt.Errorf("robot radius can't be negative, got %f", c.RobotRadius)
+	case c.Sensor.MaxRange < 0:
+		return fmt.Errorf("sensor range can't be negative, got %f", c.Sensor.MaxRange)
+	case c.Sensor.FieldOfView < 0:
+		return fmt.Errorf("sensor field of view can't be negative, got %f", c.Sensor.FieldOfView)
+	case c.VisibilitySpacing < 0:
+		return fmt.Errorf("visibility grid spacing can't be negative, got %f", c.VisibilitySpacing)
+	case c.IntegrationTolerance < 0:
+		return fmt.Errorf("integration tolerance can't be negative, got %f", c.IntegrationTolerance)
+	case c.CoverageCellSize < 0:
+		return fmt.Errorf("coverage cell size can't be negative, got %f", c.CoverageCellSize)
+	case c.TurningRadius < 0:
+		return fmt.Errorf("turning radius can't be negative, got %f", c.TurningRadius)
+	case c.ExecutionSpeed < 0:
+		return fmt.Errorf("execution speed can't be negative, got %f", c.ExecutionSpeed)
+	case c.ExecutionBudget < 0:
+		return fmt.Errorf("execution budget can't be negative, got %v", c.ExecutionBudget)
+	case c.ExecutionSpeed > 0 && c.ExecutionBudget == 0:
+		return errors.New("execution budget must be positive to execute, or the tree never grows")
+	case c.ExecutionRange < 0:
+		return fmt.Errorf("execution range can't be negative, got %f", c.ExecutionRange)
+	case c.RtreeMinChildren < 1 || c.RtreeMaxChildren < 2*c.RtreeMinChildren:
+		return fmt.Errorf("rtree needs 1 <= min children <= max children / 2, got %d and %d", c.RtreeMinChildren, c.RtreeMaxChildren)
+	case c.StartPoint != nil && !c.inBounds(c.StartPoint):
+		return fmt.Errorf("start point %v is outside the map", *c.StartPoint)
+	case c.EndPoint != nil && !c.inBounds(c.EndPoint):
+		return fmt.Errorf("end point %v is outside the map", *c.EndPoint)
+	}
+	return nil
+}
+
+func (c *PlannerConfig) newRtree() *rtreego.Rtree {
+	return rtreego.NewTree(2, c.RtreeMinChildren, c.RtreeMaxChildren)
+}
+
+// newPlannerBase validates the config and fills in everything the planners have in common
+func newPlannerBase(obstacleMap ObstacleMap, obstacleRects []*geom.Rect, config PlannerConfig) (PlannerBase, error) {
+	if obstacleMap == nil {
+		return PlannerBase{}, errors.New("obstacle map is required")
+	}
+	if err := config.Validate(); err != nil {
+		return PlannerBase{}, err
+	}
+
+	// only a vector map knows the shape of its polygons and circles, the viewshed needs them either way
+	var obstaclePolygons []Polygon
+	var obstacleCircles []*Circle
+	if vectorMap, ok := obstacleMap.(*VectorObstacleMap); ok {
+		if obstacleRects == nil {
+			obstacleRects = vectorMap.Rects
+		}
+		obstaclePolygons = vectorMap.Polygons
+		obstacleCircles = vectorMap.Circles
+	}
+
+	if config.RobotRadius > 0 {
+		var err error
+		if obstacleMap, err = InflateObstacleMap(obstacleMap, config.RobotRadius); err != nil {
+			return PlannerBase{}, err
+		}
+	}
+
+	width, height := config.Width, config.Height
+
+	costFunction := config.CostFunction
+	if costFunction == nil {
+		unseenAreaCost := NewUnseenAreaCost(width, height, obstacleRects, obstaclePolygons, obstacleCircles)
+		unseenAreaCost.Sensor = config.Sensor
+		unseenAreaCost.IntegrationTolerance = config.IntegrationTolerance
+		unseenAreaCost.MinUnseen = config.MinUnseen
+		// a camera facing along each edge sees something different on every edge, which a grid can't hold
+		if config.VisibilitySpacing > 0 && !config.Sensor.IsDirectional() {
+			field, err := SharedVisibilityField(width, height, obstacleRects, obstaclePolygons, obstacleCircles, config.Sensor, config.VisibilitySpacing, config.VisibilityCacheDir)
+			if err != nil {
+				return PlannerBase{}, err
+			}
+			unseenAreaCost.Field = field
+			unseenAreaCost.fieldCacheDir = config.VisibilityCacheDir
+		}
+		costFunction = unseenAreaCost
+	}
+
+	if config.InformedSampling {
+		if bounded, ok := costFunction.(BoundedCostFunction); !ok {
+			log.Printf("informed sampling has no effect, a %T can't bound its cost", costFunction)
+		} else if bounded.MinCostPerDistance() <= 0 {
+			log.Printf("informed sampling has no effect, a %T may cost nothing per distance", costFunction)
+		}
+	}
+
+	startPoint := config.StartPoint
+	if startPoint == nil {
+		startPoint = randomOpenAreaPoint(obstacleMap, width, height)
+	}
+	//make sure the endpoint is at least half the screen away from the start to guarantee some difficulty
+	endPoint := config.EndPoint
+	if endPoint == nil {
+		for endPoint == nil || euclideanDistance(startPoint, endPoint) < float64(width)/2.0 {
+			endPoint = randomOpenAreaPoint(obstacleMap, width, height)
+		}
+	}
+
+	base := PlannerBase{
+		obstacleMap:        obstacleMap,
+		obstacleRects:      obstacleRects,
+		obstaclePolygons:   obstaclePolygons,
+		obstacleCircles:    obstacleCircles,
+		rtree:              config.newRtree(),
+		maxSegment:         config.MaxSegment,
+		rewireNeighborhood: config.MaxSegment * config.RewireFactor,
+		width:              width,
+		height:             height,
+		StartPoint:         startPoint,
+		EndPoint:           endPoint,
+		mapArea:            float64(width * height),
+		informedSampling:   config.InformedSampling,
+		haltonX:            halton.NewHaltonSampler(19),
+		haltonY:            halton.NewHaltonSampler(23),
+		costFunction:       costFunction,
+		vehicle:            vehicle{speed: config.ExecutionSpeed, budget: config.ExecutionBudget, reach: config.ExecutionRange},
+		lock:               &sync.RWMutex{}}
+
+	if config.CoverageCellSize > 0 {
+		base.coverage = newCoverageGrid(width, height, obstacleRects, obstaclePolygons, obstacleCircles, config.CoverageCellSize)
+	}
+
+	base.Viewshed.Sensor = config.Sensor
+	base.Viewshed.LoadMap(float64(width), float64(height), 0, obstacleRects, polygonOutlines(obstaclePolygons), circleBlocks(obstacleCircles), nil)
+
+	return base, nil
+}
@@ -0,0 +1,334 @@
+package rrtstar
+
+import (
+	"math"
+	"sync"
+
+	"github.com/brychanrobot/go-rrt-star/viewshed"
+	"github.com/skelterjohn/geom"
+)
+
+const (
+	unseenK   = 4.0
+	distanceK = 0.00
+
+	// integrationStep is the longest piece of an edge integrated before it's checked for error
+	integrationStep = 5.0
+	// maxIntegrationDepth caps how many times a piece is halved, whatever the tolerance
+	maxIntegrationDepth = 8
+
+	// maxCachedViews is how many unseen areas each generation of the cache holds
+	maxCachedViews = 1 << 16
+	// headingBuckets is how many headings a directional sensor is rounded to, so what it sees from a
+	// point can be cached for every edge leaving in roughly the same direction
+	headingBuckets = 72
+)
+
+// CostFunction computes the cost of traveling the edge between two points
+type CostFunction interface {
+	EdgeCost(p1, p2 *geom.Coord) float64
+}
+
+// PointCostFunction is a CostFunction that also assigns a cost to individual points
+type PointCostFunction interface {
+	CostFunction
+	PointCost(point *geom.Coord) float64
+}
+
+// BoundedCostFunction is a CostFunction that can guarantee a minimum cost per unit of distance.
+// Informed sampling uses the bound to skip regions that can't shorten the best path.
+type BoundedCostFunction interface {
+	CostFunction
+	MinCostPerDistance() float64
+}
+
+// EuclideanCost is the straight line length of an edge
+type EuclideanCost struct{}
+
+// EdgeCost returns the distance between p1 and p2
+func (c EuclideanCost) EdgeCost(p1, p2 *geom.Coord) float64 {
+	return euclideanDistance(p1, p2)
+}
+
+// MinCostPerDistance is exactly one since the cost is the distance
+func (c EuclideanCost) MinCostPerDistance() float64 {
+	return 1
+}
+
+// UnseenAreaCost weighs edges by how much of the map can't be seen along them
+type UnseenAreaCost struct {
+	UnseenK   float64
+	DistanceK float64
+	// MinUnseen is a lower bound on PointCost anywhere on the map. It defaults to 0, which
+	// is always admissible but only bounds the informed region when DistanceK is positive. When
+	// every cost is read from Field, the lowest point of the field is used if it's higher
+	MinUnseen float64
+	// Sensor limits what can be seen from each point. It sees everything by default
+	Sensor viewshed.Sensor
+	// Field answers PointCost from a precomputed grid instead of sweeping every point. It has to
+	// have been computed with the same Sensor
+	Field *VisibilityField
+	// IntegrationTolerance, when positive, integrates the unseen area along each edge to within
+	// this much instead of averaging the two ends, so edges past corners and doorways are costed right.
+	// Edges are swept exactly while integrating, since Field would smooth over anything finer than its grid
+	IntegrationTolerance float64
+
+	viewshed     viewshed.Viewshed
+	mapArea      float64
+	obstacleArea float64
+	// the obstacles the cost was loaded with, so a change to them can be told from a new map
+	obstacleRects    []*geom.Rect
+	obstaclePolygons []Polygon
+	obstacleCircles  []*Circle
+	views            viewCache
+	fieldCacheDir    string
+	// lock is held while a cost is worked out, since the sweeps share the viewshed and cache and the
+	// planner's readers can ask for costs alongside it
+	lock sync.Mutex
+}
+
+// viewKey is a point and the heading bucket a directional sensor looks from it with
+type viewKey struct {
+	point   geom.Coord
+	heading int
+}
+
+// viewCache remembers swept unseen areas in two generations. When the newer one fills up the older
+// one is dropped, so the points that keep being costed stay while the rest are let go
+type viewCache struct {
+	current  map[viewKey]float64
+	previous map[viewKey]float64
+}
+
+func (v *viewCache) get(key viewKey) (float64, bool) {
+	if value, ok := v.current[key]; ok {
+		return value, true
+	}
+	if value, ok := v.previous[key]; ok {
+		v.put(key, value)
+		return value, true
+	}
+	return 0, false
+}
+
+func (v *viewCache) put(key viewKey, value float64) {
+	if len(v.current) >= maxCachedViews {
+		v.previous = v.current
+		v.current = nil
+	}
+	if v.current == nil {
+		v.current = make(map[viewKey]float64)
+	}
+	v.current[key] = value
+}
+
+func (v *viewCache) clear() {
+	v.current = nil
+	v.previous = nil
+}
+
+func obstaclesArea(obstacleRects []*geom.Rect, obstaclePolygons []Polygon, obstacleCircles []*Circle) float64 {
+	obstacleArea := 0.0
+	for _, obstacle := range obstacleRects {
+		obstacleArea += obstacle.Width() * obstacle.Height()
+	}
+	for _, obstacle := range obstaclePolygons {
+		obstacleArea += obstacle.area()
+	}
+	for _, obstacle := range obstacleCircles {
+		obstacleArea += obstacle.area()
+	}
+	return obstacleArea
+}
+
+// unseenFraction turns the area seen from a point into the part of the map that can't be seen
+func unseenFraction(viewArea, mapArea, obstacleArea float64) float64 {
+	return (mapArea - obstacleArea - viewArea) / (mapArea + obstacleArea)
+}
+
+// NewUnseenAreaCost creates an unseen area cost for a map
+func NewUnseenAreaCost(width, height int, obstacleRects []*geom.Rect, obstaclePolygons []Polygon, obstacleCircles []*Circle) *UnseenAreaCost {
+	cost := &UnseenAreaCost{
+		UnseenK:          unseenK,
+		DistanceK:        distanceK,
+		mapArea:          float64(width * height),
+		obstacleArea:     obstaclesArea(obstacleRects, obstaclePolygons, obstacleCircles),
+		obstacleRects:    obstacleRects,
+		obstaclePolygons: obstaclePolygons,
+		obstacleCircles:  obstacleCircles}
+
+	cost.viewshed.LoadMap(float64(width), float64(height), 0, obstacleRects, polygonOutlines(obstaclePolygons), circleBlocks(obstacleCircles), nil)
+
+	return cost
+}
+
+func (c *UnseenAreaCost) getViewArea(point *geom.Coord, heading float64) float64 {
+	c.viewshed.Sensor = c.Sensor
+	c.viewshed.Sensor.Heading = heading
+	c.viewshed.UpdateCenterLocation(point.X, point.Y)
+	c.viewshed.Sweep()
+	return viewshed.Area2DPolygon(c.viewshed.ViewablePolygon)
+}
+
+func (c *UnseenAreaCost) getUnseenArea(point *geom.Coord, heading float64) float64 {
+	return unseenFraction(c.getViewArea(point, heading), c.mapArea, c.obstacleArea)
+}
+
+// unseenAreaFrom sweeps the unseen area from point, or finds it in the cache. A directional sensor
+// faces heading rounded to the nearest of headingBuckets, everything else faces the Sensor's Heading
+func (c *UnseenAreaCost) unseenAreaFrom(point *geom.Coord, heading float64) float64 {
+	key := viewKey{point: *point}
+	if c.Sensor.IsDirectional() {
+		key.heading = int(math.Floor(heading/(2*math.Pi)*headingBuckets+0.5)) % headingBuckets
+		if key.heading < 0 {
+			key.heading += headingBuckets
+		}
+		heading = float64(key.heading) * 2 * math.Pi / headingBuckets
+	} else {
+		heading = c.Sensor.Heading
+	}
+
+	value, ok := c.views.get(key)
+	if !ok {
+		value = c.getUnseenArea(point, heading)
+		c.views.put(key, value)
+	}
+	return value
+}
+
+// PointCost returns the fraction of the map that can't be seen from point with the sensor facing its Heading
+func (c *UnseenAreaCost) PointCost(point *geom.Coord) float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.pointCost(point)
+}
+
+func (c *UnseenAreaCost) pointCost(point *geom.Coord) float64 {
+	//return (c.mapArea - c.getViewArea(point)) / c.mapArea
+	if c.Field != nil {
+		return c.Field.UnseenArea(point)
+	}
+	return c.unseenAreaFrom(point, c.Sensor.Heading)
+}
+
+func (c *UnseenAreaCost) getEdgeUnseenArea(p1, p2 *geom.Coord) (float64, float64) {
+	/*angle := angleBetweenPoints(*p1, *p2)
+	dist := euclideanDistance(p1, p2)
+	sum := 0.0
+	for i := 5.0; i < dist; i += 5 {
+		x := p.maxSegment*math.Cos(angle) + p1.X
+		y := p.maxSegment*math.Sin(angle) + p1.Y
+		point := geom.Coord{X: x, Y: y}
+		value := p.unseenAreaMap[point]
+		if value == 0 {
+			value = p.getUnseenArea(&point)
+			p.unseenAreaMap[point] = value
+		}
+
+		sum += value
+	}
+	return sum
+	*/
+
+	dist := euclideanDistance(p1, p2)
+	heading := angleBetweenPoints(*p1, *p2)
+
+	integrating := c.IntegrationTolerance > 0 && dist > 0
+
+	var a1, a2 float64
+	if c.Sensor.IsDirectional() || integrating {
+		// the camera faces along the edge, so what it sees is cached by point and heading together.
+		// integrating sweeps the ends as well as the points between them so they all agree
+		a1 = c.unseenAreaFrom(p1, heading)
+		a2 = c.unseenAreaFrom(p2, heading)
+	} else {
+		a1 = c.pointCost(p1)
+		a2 = c.pointCost(p2)
+	}
+
+	if integrating {
+		return c.integrateUnseenArea(p1, p2, a1, a2, heading, dist), dist
+	}
+
+	unseenArea := ((a1 + a2) / 2.0) * dist
+
+	return unseenArea, dist
+}
+
+// unseenAreaAlong sweeps the unseen area at a point partway along an edge. Points along edges rarely
+// come up again, so unlike the ends they aren't cached
+func (c *UnseenAreaCost) unseenAreaAlong(point *geom.Coord, heading float64) float64 {
+	return c.getUnseenArea(point, heading)
+}
+
+// integrateUnseenArea integrates the unseen area along the edge with adaptive simpson's rule. The
+// edge starts out cut into pieces no longer than integrationStep so narrow gaps aren't stepped over,
+// then any piece whose estimate is off by more than its share of the tolerance is split again
+func (c *UnseenAreaCost) integrateUnseenArea(p1, p2 *geom.Coord, a1, a2, heading, dist float64) float64 {
+	unseenAt := func(t float64) float64 {
+		return c.unseenAreaAlong(&geom.Coord{X: p1.X + t*(p2.X-p1.X), Y: p1.Y + t*(p2.Y-p1.Y)}, heading)
+	}
+
+	pieces := int(math.Ceil(dist / integrationStep))
+	// the tolerance is on the whole edge, but the pieces are integrated over how far along it they are
+	tolerance := c.IntegrationTolerance / dist / float64(pieces)
+
+	sum := 0.0
+	start, startValue := 0.0, a1
+	for piece := 1; piece <= pieces; piece++ {
+		end := float64(piece) / float64(pieces)
+		endValue := a2
+		if piece < pieces {
+			endValue = unseenAt(end)
+		}
+
+		middle := (start + end) / 2
+		middleValue := unseenAt(middle)
+		whole := simpson(start, end, startValue, middleValue, endValue)
+		sum += adaptiveSimpson(unseenAt, start, end, startValue, middleValue, endValue, whole, tolerance, maxIntegrationDepth)
+
+		start, startValue = end, endValue
+	}
+
+	return sum * dist
+}
+
+func simpson(start, end, startValue, middleValue, endValue float64) float64 {
+	return (end - start) / 6 * (startValue + 4*middleValue + endValue)
+}
+
+// adaptiveSimpson splits the interval in half until the halves agree with the whole to within
+// tolerance, or it runs out of depth
+func adaptiveSimpson(f func(float64) float64, start, end, startValue, middleValue, endValue, whole, tolerance float64, depth int) float64 {
+	middle := (start + end) / 2
+	leftValue := f((start + middle) / 2)
+	rightValue := f((middle + end) / 2)
+	left := simpson(start, middle, startValue, leftValue, middleValue)
+	right := simpson(middle, end, middleValue, rightValue, endValue)
+
+	if depth <= 0 || math.Abs(left+right-whole) <= 15*tolerance {
+		return left + right + (left+right-whole)/15
+	}
+	return adaptiveSimpson(f, start, middle, startValue, leftValue, middleValue, left, tolerance/2, depth-1) +
+		adaptiveSimpson(f, middle, end, middleValue, rightValue, endValue, right, tolerance/2, depth-1)
+}
+
+// EdgeCost returns the weighted sum of the edge length and the unseen area along it
+func (c *UnseenAreaCost) EdgeCost(p1, p2 *geom.Coord) float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	unseenArea, dist := c.getEdgeUnseenArea(p1, p2)
+	return dist*c.DistanceK + unseenArea*c.UnseenK
+}
+
+// MinCostPerDistance is the cost of an edge that only passes points with MinUnseen unseen area
+func (c *UnseenAreaCost) MinCostPerDistance() float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	minUnseen := c.MinUnseen
+	// interpolating can't go under the lowest grid point, but sweeping between them can
+	if c.Field != nil && c.IntegrationTolerance <= 0 && !c.Sensor.IsDirectional() {
+		minUnseen = math.Max(minUnseen, c.Field.minUnseen)
+	}
+	return c.DistanceK + minUnseen*c.UnseenK
+}
@@ -0,0 +1,63 @@
+package rrtstar
+
+import (
+	"math"
+	"testing"
+
+	"github.com/skelterjohn/geom"
+)
+
+func TestAdaptiveSimpsonMatchesTheIntegral(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		f          func(x float64) float64
+		start, end float64
+		integral   float64
+	}{
+		{"a cubic, which simpson's rule gets exactly", func(x float64) float64 { return x*x*x - 2*x + 1 }, -1, 2, 3.75},
+		{"a sine", math.Sin, 0, math.Pi, 2},
+		{"a peak", func(x float64) float64 { return 1 / (1 + 400*(x-0.3)*(x-0.3)) }, 0, 1, (math.Atan(20*0.7) + math.Atan(20*0.3)) / 20},
+		{"a step", func(x float64) float64 {
+			if x < 0.37 {
+				return 0.2
+			}
+			return 0.9
+		}, 0, 1, 0.2*0.37 + 0.9*0.63},
+	} {
+		const tolerance = 1e-7
+		middle := (test.start + test.end) / 2
+		startValue, middleValue, endValue := test.f(test.start), test.f(middle), test.f(test.end)
+		whole := simpson(test.start, test.end, startValue, middleValue, endValue)
+		integral := adaptiveSimpson(test.f, test.start, test.end, startValue, middleValue, endValue, whole, tolerance, 40)
+		if math.Abs(integral-test.integral) > 10*tolerance {
+			t.Errorf("%s: integrated to %.10f, expected %.10f", test.name, integral, test.integral)
+		}
+	}
+}
+
+func TestIntegratedUnseenAreaMatchesFineSampling(t *testing.T) {
+	rects := []*geom.Rect{
+		{Min: geom.Coord{X: 40, Y: 40}, Max: geom.Coord{X: 60, Y: 50}},
+		{Min: geom.Coord{X: 75, Y: 10}, Max: geom.Coord{X: 80, Y: 35}}}
+	cost := NewUnseenAreaCost(100, 100, rects, nil, nil)
+	cost.UnseenK, cost.DistanceK = 1, 0
+	cost.IntegrationTolerance = 1e-3
+
+	// the edge runs just past both rects, so what can be seen changes sharply along it
+	p1, p2 := &geom.Coord{X: 10, Y: 30}, &geom.Coord{X: 95, Y: 38}
+	const samples = 4000
+	dist := euclideanDistance(p1, p2)
+	heading := angleBetweenPoints(*p1, *p2)
+	reference := 0.0
+	previous := cost.getUnseenArea(p1, heading)
+	for i := 1; i <= samples; i++ {
+		along := float64(i) / samples
+		value := cost.getUnseenArea(&geom.Coord{X: p1.X + along*(p2.X-p1.X), Y: p1.Y + along*(p2.Y-p1.Y)}, heading)
+		reference += (previous + value) / 2 * dist / samples
+		previous = value
+	}
+
+	if integrated := cost.EdgeCost(p1, p2); math.Abs(integrated-reference) > 2*cost.IntegrationTolerance {
+		t.Errorf("integrated %f unseen along the edge, sampling finely gives %f", integrated, reference)
+	}
+}
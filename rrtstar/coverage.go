@@ -0,0 +1,350 @@
+package rrtstar
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+
+	"github.com/brychanrobot/go-rrt-star/viewshed"
+	"github.com/dhconnelly/rtreego"
+	"github.com/skelterjohn/geom"
+)
+
+// coverageGrid cuts the map into square cells so what's been seen from many points can be unioned
+// by marking cells instead of clipping polygons
+type coverageGrid struct {
+	cellSize float64
+	columns  int
+	rows     int
+	// free is how many cells are outside every obstacle, the most that can ever be seen
+	free int
+}
+
+// coverageMask marks the cells of a coverageGrid that have been seen, a bit per cell
+type coverageMask []uint64
+
+func newCoverageGrid(width, height int, obstacleRects []*geom.Rect, obstaclePolygons []Polygon, obstacleCircles []*Circle, cellSize float64) *coverageGrid {
+	grid := &coverageGrid{
+		cellSize: cellSize,
+		columns:  int(math.Ceil(float64(width) / cellSize)),
+		rows:     int(math.Ceil(float64(height) / cellSize))}
+
+	// a cell is seen when its middle is, so it's free when its middle is
+	obstacles := NewVectorObstacleMap(width, height, obstacleRects, obstaclePolygons, obstacleCircles)
+	for row := 0; row < grid.rows; row++ {
+		for col := 0; col < grid.columns; col++ {
+			if !obstacles.PointIntersects(grid.cellCenter(row, col)) {
+				grid.free++
+			}
+		}
+	}
+
+	return grid
+}
+
+func (g *coverageGrid) cellCenter(row, col int) geom.Coord {
+	return geom.Coord{X: (float64(col) + 0.5) * g.cellSize, Y: (float64(row) + 0.5) * g.cellSize}
+}
+
+func (g *coverageGrid) newMask() coverageMask {
+	return make(coverageMask, (g.columns*g.rows+63)/64)
+}
+
+// rasterize marks every cell whose middle is inside polygon
+func (g *coverageGrid) rasterize(polygon []*geom.Coord) coverageMask {
+	mask := g.newMask()
+	viewshed.FillCells(polygon, g.cellSize, g.columns, g.rows, func(row, first, last int) {
+		for cell := row*g.columns + first; cell <= row*g.columns+last; cell++ {
+			mask[cell/64] |= 1 << uint(cell%64)
+		}
+	})
+	return mask
+}
+
+// fraction is how much of the free map count cells make up
+func (g *coverageGrid) fraction(count int) float64 {
+	if g.free == 0 {
+		return 0
+	}
+	return float64(count) / float64(g.free)
+}
+
+func (m coverageMask) count() int {
+	count := 0
+	for _, word := range m {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+// unionCount is how many cells are marked in either mask, without building the union
+func (m coverageMask) unionCount(other coverageMask) int {
+	count := 0
+	for i, word := range m {
+		count += bits.OnesCount64(word | other[i])
+	}
+	return count
+}
+
+// union marks the cells of both masks in into, reusing it when it's the right size. other may be nil
+func (m coverageMask) union(other, into coverageMask) coverageMask {
+	if len(into) != len(m) {
+		into = make(coverageMask, len(m))
+	}
+	copy(into, m)
+	for i := range other {
+		into[i] |= other[i]
+	}
+	return into
+}
+
+// coverageCost is the cost of traveling dist while the part of the map left unseen goes from before to after
+func coverageCost(dist, before, after float64) float64 {
+	return dist*distanceK + dist*(before+after)/2*unseenK
+}
+
+// CoverageRrtStar is an rrt* that plans for the most area seen along the whole path. Each node
+// carries everything seen on the way to it, and edges cost whatever is still unseen while they are
+// traveled, so going back over ground that's already been seen doesn't make a path any cheaper.
+// It costs edges itself and ignores the configured CostFunction
+type CoverageRrtStar struct {
+	PlannerBase
+	viewshed *viewshed.Viewshed
+	// collected is what the vehicle has seen on the way to the root, which the root starts out with
+	collected coverageMask
+}
+
+// NewCoverageRrtStar creates a new rrt star that plans for coverage
+func NewCoverageRrtStar(obstacleMap ObstacleMap, obstacleRects []*geom.Rect, config PlannerConfig) (*CoverageRrtStar, error) {
+	base, err := newPlannerBase(obstacleMap, obstacleRects, config)
+	if err != nil {
+		return nil, err
+	}
+	if base.coverage == nil {
+		return nil, errors.New("coverage planning needs a positive coverage cell size")
+	}
+
+	coverageRrtStar := &CoverageRrtStar{PlannerBase: base, viewshed: base.Viewshed.Clone()}
+	coverageRrtStar.nodeThreshold = uint64(config.NodeDensity * float64(config.Width*config.Height))
+
+	coverageRrtStar.Root = &Node{parent: nil, Coord: *coverageRrtStar.StartPoint, CumulativeCost: 0, Heading: config.StartHeading}
+	coverageRrtStar.rtree.Insert(coverageRrtStar.Root)
+	coverageRrtStar.NumNodes = 1
+
+	coverageRrtStar.attach(coverageRrtStar.Root, coverageRrtStar.viewFrom(coverageRrtStar.StartPoint, config.StartHeading))
+
+	return coverageRrtStar, nil
+}
+
+// viewFrom marks the cells the sensor sees from point facing heading
+func (c *CoverageRrtStar) viewFrom(point *geom.Coord, heading float64) coverageMask {
+	c.viewshed.Sensor.Heading = heading
+	c.viewshed.UpdateCenterLocation(point.X, point.Y)
+	c.viewshed.Sweep()
+	return c.coverage.rasterize(c.viewshed.ViewablePolygon)
+}
+
+// viewAlong is what's seen at the end of the edge from parent to point. A camera faces along the
+// edge, so then it depends on the edge and has to be swept again
+func (c *CoverageRrtStar) viewAlong(parent *Node, point geom.Coord, known coverageMask) coverageMask {
+	if known != nil && !c.viewshed.Sensor.IsDirectional() {
+		return known
+	}
+	return c.viewFrom(&point, angleBetweenPoints(parent.Coord, point))
+}
+
+// edgeCost is the cost of going from parent to point and seeing view when getting there
+func (c *CoverageRrtStar) edgeCost(parent *Node, point geom.Coord, view coverageMask) float64 {
+	after := c.coverage.fraction(parent.seen.unionCount(view))
+	return coverageCost(euclideanDistance(&parent.Coord, &point), 1-parent.Coverage, 1-after)
+}
+
+// attach gives node its view and works out what's been seen and what it cost to get to it and to
+// everything below it, since all of that depends on what was seen before
+func (c *CoverageRrtStar) attach(node *Node, view coverageMask) {
+	node.view = view
+	if node.parent == nil {
+		node.seen = view.union(c.collected, node.seen)
+		node.CumulativeCost = 0
+	} else {
+		node.seen = node.parent.seen.union(view, node.seen)
+		node.CumulativeCost = node.parent.CumulativeCost + coverageCost(euclideanDistance(&node.parent.Coord, &node.Coord), 1-node.parent.Coverage, 1-c.coverage.fraction(node.seen.count()))
+	}
+	node.Coverage = c.coverage.fraction(node.seen.count())
+
+	for _, child := range node.Children {
+		// the edge to the child hasn't changed, so neither has what's seen at the end of it
+		c.attach(child, child.view)
+	}
+}
+
+func (c *CoverageRrtStar) neighborsOf(point geom.Coord, neighborhoodSize float64) []*Node {
+	rtreePoint := rtreego.Point{point.X, point.Y}
+	spatialNeighbors := c.rtree.SearchIntersect(rtreePoint.ToRect(neighborhoodSize))
+	neighbors := make([]*Node, 0, len(spatialNeighbors))
+	for _, spatialNeighbor := range spatialNeighbors {
+		neighbor := spatialNeighbor.(*Node)
+		if neighbor.Coord != point {
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors
+}
+
+// getBestParent finds the neighbor that reaches point with the least left unseen along the way
+func (c *CoverageRrtStar) getBestParent(neighbors []*Node, point geom.Coord) (*Node, coverageMask) {
+	var view coverageMask
+	if !c.viewshed.Sensor.IsDirectional() {
+		view = c.viewFrom(&point, 0)
+	}
+
+	bestCumulativeCost := math.MaxFloat64
+	var bestParent *Node
+	var bestView coverageMask
+	for _, neighbor := range neighbors {
+		if c.lineIntersectsObstacle(neighbor.Coord, point) {
+			continue
+		}
+
+		neighborView := c.viewAlong(neighbor, point, view)
+		if cost := c.edgeCost(neighbor, point, neighborView); cost+neighbor.CumulativeCost < bestCumulativeCost {
+			bestCumulativeCost = cost + neighbor.CumulativeCost
+			bestParent = neighbor
+			bestView = neighborView
+		}
+	}
+
+	return bestParent, bestView
+}
+
+// rewireThrough moves each neighbor under node if that leaves less unseen on the way to it
+func (c *CoverageRrtStar) rewireThrough(node *Node, neighbors []*Node) {
+	for _, neighbor := range neighbors {
+		if neighbor == node || neighbor == node.parent || c.lineIntersectsObstacle(node.Coord, neighbor.Coord) {
+			continue
+		}
+
+		view := c.viewAlong(node, neighbor.Coord, neighbor.view)
+		cost := c.edgeCost(node, neighbor.Coord, view)
+		if cost+node.CumulativeCost < neighbor.CumulativeCost {
+			neighbor.Rewire(node, cost)
+			c.attach(neighbor, view)
+		}
+	}
+}
+
+func (c *CoverageRrtStar) sampleWithNewNode() {
+	c.extendTree(c.rtree, c.nextSamplePoint())
+}
+
+// extendTree steers from the nearest node in rtree toward point and adds a node there, joined to
+// whichever neighbor leaves the least unseen. It returns nil if no node could be added
+func (c *CoverageRrtStar) extendTree(rtree *rtreego.Rtree, point geom.Coord) *Node {
+	nnSpatial := rtree.NearestNeighbor(rtreego.Point{point.X, point.Y})
+	nn := nnSpatial.(*Node)
+
+	if euclideanDistance(&nn.Coord, &point) > c.maxSegment {
+		angle := angleBetweenPoints(nn.Coord, point)
+		point = geom.Coord{X: c.maxSegment*math.Cos(angle) + nn.Coord.X, Y: c.maxSegment*math.Sin(angle) + nn.Coord.Y}
+	}
+
+	if c.obstacleMap.PointIntersects(point) {
+		return nil
+	}
+
+	neighbors := c.neighborsOf(point, c.rewireNeighborhood)
+	bestParent, view := c.getBestParent(neighbors, point)
+	if bestParent == nil {
+		return nil
+	}
+
+	newNode := bestParent.AddAndCreateChild(point, 0, 0.0)
+	c.attach(newNode, view)
+	c.NumNodes++
+	rtree.Insert(newNode)
+
+	c.rewireThrough(newNode, neighbors)
+	return newNode
+}
+
+func (c *CoverageRrtStar) sampleWithoutNewNode() {
+	point := c.nextSamplePoint()
+	neighbors := c.neighborsOf(point, c.rewireNeighborhood)
+
+	var bestNeighbor *Node
+	for _, neighbor := range neighbors {
+		if bestNeighbor == nil || neighbor.CumulativeCost < bestNeighbor.CumulativeCost {
+			bestNeighbor = neighbor
+		}
+	}
+
+	if bestNeighbor != nil {
+		c.rewireThrough(bestNeighbor, neighbors)
+	}
+}
+
+func (c *CoverageRrtStar) refreshBestPath() {
+	if !c.isGoalReachable() {
+		if c.endNode == nil {
+			c.endNode = &Node{Coord: *c.EndPoint}
+			c.lookFromGoal()
+		}
+		c.joinGoal(c.rtree, c.coverageEdges(), c.rewireNeighborhood)
+	}
+	c.traceBestPath()
+}
+
+// lookFromGoal sweeps what the goal node sees from the end point once, rather than for every
+// neighbor that might join it. A camera sees whatever the edge it arrives along faces, so that's
+// left to the edges
+func (c *CoverageRrtStar) lookFromGoal() {
+	c.endNode.view = nil
+	if !c.viewshed.Sensor.IsDirectional() {
+		c.endNode.view = c.viewFrom(c.EndPoint, 0)
+	}
+}
+
+// moveStartPoint takes the root to the moved start without adding a node. Everything is seen from
+// somewhere else now, so the whole tree's coverage is worked out again
+func (c *CoverageRrtStar) moveStartPoint(dx, dy float64) {
+	if dx != 0 || dy != 0 {
+		c.StartPoint.X += dx
+		c.StartPoint.Y += dy
+
+		c.forgetRemoved(c.moveRoot(*c.StartPoint, c.coverageEdges()))
+		c.attach(c.Root, c.viewFrom(c.StartPoint, c.Root.Heading))
+		c.rewireThrough(c.Root, c.neighborsOf(c.Root.Coord, c.rewireNeighborhood*1.5))
+		c.refreshBestPath()
+	}
+}
+
+func (c *CoverageRrtStar) moveEndPoint(dx, dy float64) {
+	if dx != 0 || dy != 0 {
+		c.EndPoint.X += dx
+		c.EndPoint.Y += dy
+
+		if c.endNode != nil && c.endNode != c.Root {
+			c.lookFromGoal()
+		}
+		c.trackGoal(c.rtree, c.coverageEdges(), c.extendTree)
+		c.traceBestPath()
+	}
+}
+
+// bestPathCoverage reads the coverage the end node already carries
+func (c *CoverageRrtStar) bestPathCoverage() float64 {
+	if !c.isGoalReachable() {
+		return 0
+	}
+	return c.endNode.Coverage * 100
+}
+
+func (c *CoverageRrtStar) sample() {
+	c.IsAddingNodes = c.NumNodes < c.nodeThreshold
+	if c.IsAddingNodes {
+		c.sampleWithNewNode()
+	} else {
+		c.sampleWithoutNewNode()
+	}
+	c.refreshBestPath()
+}
@@ -0,0 +1,102 @@
+package rrtstar
+
+import (
+	"math"
+	"testing"
+
+	"github.com/skelterjohn/geom"
+)
+
+func TestCoverageMasksUnion(t *testing.T) {
+	// a 20x20 grid, so the masks run over several words
+	obstacle := &geom.Rect{Min: geom.Coord{X: 0, Y: 0}, Max: geom.Coord{X: 20, Y: 100}}
+	grid := newCoverageGrid(100, 100, []*geom.Rect{obstacle}, nil, nil, 5)
+	if grid.columns != 20 || grid.rows != 20 || grid.free != 400-4*20 {
+		t.Fatalf("a %dx%d grid with %d free cells", grid.columns, grid.rows, grid.free)
+	}
+
+	// no cell middle lands on an edge of either
+	triangle := Polygon{{X: 10, Y: 10}, {X: 90, Y: 60}, {X: 30, Y: 95}}
+	rect := Polygon{{X: 40, Y: 20}, {X: 80, Y: 20}, {X: 80, Y: 70}, {X: 40, Y: 70}}
+	a, b := grid.rasterize(triangle), grid.rasterize(rect)
+
+	inA, inB, inEither := 0, 0, 0
+	for row := 0; row < grid.rows; row++ {
+		for col := 0; col < grid.columns; col++ {
+			center := grid.cellCenter(row, col)
+			cell := row*grid.columns + col
+			marked := func(mask coverageMask) bool { return mask[cell/64]&(1<<uint(cell%64)) != 0 }
+			if marked(a) != triangle.ContainsPoint(center) || marked(b) != rect.ContainsPoint(center) {
+				t.Fatalf("the cell at %v is marked wrong", center)
+			}
+			if marked(a) {
+				inA++
+			}
+			if marked(b) {
+				inB++
+			}
+			if marked(a) || marked(b) {
+				inEither++
+			}
+		}
+	}
+	if inA == 0 || inB == 0 || inEither == inA+inB {
+		t.Fatalf("the shapes should overlap: %d, %d and %d in either", inA, inB, inEither)
+	}
+
+	if a.count() != inA || b.count() != inB {
+		t.Errorf("counted %d and %d cells, expected %d and %d", a.count(), b.count(), inA, inB)
+	}
+	if a.unionCount(b) != inEither || b.unionCount(a) != inEither {
+		t.Errorf("counted %d cells in either, expected %d", a.unionCount(b), inEither)
+	}
+
+	into := grid.newMask()
+	union := a.union(b, into)
+	if union.count() != inEither || &union[0] != &into[0] {
+		t.Errorf("the union has %d cells, expected %d in the mask it was given", union.count(), inEither)
+	}
+	if a.count() != inA || b.count() != inB {
+		t.Error("the union changed the masks it was made from")
+	}
+	if alone := a.union(nil, nil); alone.count() != inA || &alone[0] == &a[0] {
+		t.Error("a union with nothing isn't a copy")
+	}
+	if fraction := grid.fraction(inEither); fraction != float64(inEither)/320 {
+		t.Errorf("%d cells make up %f of the free map", inEither, fraction)
+	}
+}
+
+func TestCoverageNodesSeeWhatTheirParentsSaw(t *testing.T) {
+	rects := []*geom.Rect{{Min: geom.Coord{X: 95, Y: 40}, Max: geom.Coord{X: 105, Y: 190}}}
+	config := DefaultPlannerConfig(200, 200)
+	config.StartPoint = &geom.Coord{X: 20, Y: 100}
+	config.EndPoint = &geom.Coord{X: 180, Y: 100}
+	config.CoverageCellSize = 5
+	coverage, err := NewCoverageRrtStar(NewVectorObstacleMap(200, 200, rects, nil, nil), rects, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		coverage.Sample()
+	}
+
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		for _, child := range node.Children {
+			if union := node.seen.union(child.view, nil); child.seen.count() != union.count() || child.seen.unionCount(union) != union.count() {
+				t.Fatalf("%v hasn't seen exactly what its parent and its own view have", child.Coord)
+			}
+			if child.Coverage < node.Coverage {
+				t.Fatalf("coverage went down from %f to %f on the way to %v", node.Coverage, child.Coverage, child.Coord)
+			}
+			walk(child)
+		}
+	}
+	walk(coverage.Root)
+
+	// the end node carries a fraction and the path reports a percentage
+	if path := coverage.GetBestPath(); len(path) > 0 && math.Abs(coverage.GetBestPathCoverage()-100*coverage.endNode.Coverage) > 1e-9 {
+		t.Errorf("the best path covers %f%% but its end node %f", coverage.GetBestPathCoverage(), coverage.endNode.Coverage)
+	}
+}
@@ -0,0 +1,187 @@
+package rrtstar
+
+import (
+	"math"
+
+	"github.com/skelterjohn/geom"
+)
+
+type dubinsSegment uint32
+
+const (
+	leftTurn dubinsSegment = iota
+	straight
+	rightTurn
+)
+
+// the six words that make up every shortest dubins path
+var dubinsWords = [6][3]dubinsSegment{
+	{leftTurn, straight, leftTurn},
+	{leftTurn, straight, rightTurn},
+	{rightTurn, straight, leftTurn},
+	{rightTurn, straight, rightTurn},
+	{rightTurn, leftTurn, rightTurn},
+	{leftTurn, rightTurn, leftTurn},
+}
+
+// dubinsPath is the shortest curve between two poses for a vehicle with a minimum turning radius
+// https://github.com/AndrewWalker/Dubins-Curves
+type dubinsPath struct {
+	start        geom.Coord
+	startHeading float64
+	radius       float64
+	word         [3]dubinsSegment
+	params       [3]float64 // length of each segment divided by radius
+}
+
+func mod2pi(angle float64) float64 {
+	angle = math.Mod(angle, 2*math.Pi)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	return angle
+}
+
+// dubinsWordParams solves for the three segment lengths of a word, ok is false if the word can't connect the poses
+func dubinsWordParams(word int, alpha, beta, d float64) (params [3]float64, ok bool) {
+	sa, sb := math.Sin(alpha), math.Sin(beta)
+	ca, cb := math.Cos(alpha), math.Cos(beta)
+	cab := math.Cos(alpha - beta)
+	dd := d * d
+
+	switch word {
+	case 0: // LSL
+		pSquared := 2 + dd - 2*cab + 2*d*(sa-sb)
+		if pSquared < 0 {
+			return
+		}
+		tmp := math.Atan2(cb-ca, d+sa-sb)
+		return [3]float64{mod2pi(tmp - alpha), math.Sqrt(pSquared), mod2pi(beta - tmp)}, true
+	case 1: // LSR
+		pSquared := -2 + dd + 2*cab + 2*d*(sa+sb)
+		if pSquared < 0 {
+			return
+		}
+		p := math.Sqrt(pSquared)
+		tmp := math.Atan2(-ca-cb, d+sa+sb) - math.Atan2(-2, p)
+		return [3]float64{mod2pi(tmp - alpha), p, mod2pi(tmp - beta)}, true
+	case 2: // RSL
+		pSquared := -2 + dd + 2*cab - 2*d*(sa+sb)
+		if pSquared < 0 {
+			return
+		}
+		p := math.Sqrt(pSquared)
+		tmp := math.Atan2(ca+cb, d-sa-sb) - math.Atan2(2, p)
+		return [3]float64{mod2pi(alpha - tmp), p, mod2pi(beta - tmp)}, true
+	case 3: // RSR
+		pSquared := 2 + dd - 2*cab + 2*d*(sb-sa)
+		if pSquared < 0 {
+			return
+		}
+		tmp := math.Atan2(ca-cb, d-sa+sb)
+		return [3]float64{mod2pi(alpha - tmp), math.Sqrt(pSquared), mod2pi(tmp - beta)}, true
+	case 4: // RLR
+		tmp := (6 - dd + 2*cab + 2*d*(sa-sb)) / 8
+		if math.Abs(tmp) > 1 {
+			return
+		}
+		phi := math.Atan2(ca-cb, d-sa+sb)
+		p := mod2pi(2*math.Pi - math.Acos(tmp))
+		t := mod2pi(alpha - phi + mod2pi(p/2))
+		return [3]float64{t, p, mod2pi(alpha - beta - t + p)}, true
+	case 5: // LRL
+		tmp := (6 - dd + 2*cab + 2*d*(sb-sa)) / 8
+		if math.Abs(tmp) > 1 {
+			return
+		}
+		phi := math.Atan2(ca-cb, d+sa-sb)
+		p := mod2pi(2*math.Pi - math.Acos(tmp))
+		t := mod2pi(-alpha - phi + p/2)
+		return [3]float64{t, p, mod2pi(beta - alpha - t + p)}, true
+	}
+
+	return
+}
+
+// shortestDubinsPath finds the shortest of the six dubins words between two poses
+func shortestDubinsPath(start geom.Coord, startHeading float64, end geom.Coord, endHeading float64, radius float64) *dubinsPath {
+	dx := end.X - start.X
+	dy := end.Y - start.Y
+	d := math.Sqrt(dx*dx+dy*dy) / radius
+
+	theta := 0.0
+	if d > 0 {
+		theta = mod2pi(math.Atan2(dy, dx))
+	}
+	alpha := mod2pi(startHeading - theta)
+	beta := mod2pi(endHeading - theta)
+
+	var best *dubinsPath
+	bestLength := math.MaxFloat64
+	for word := range dubinsWords {
+		params, ok := dubinsWordParams(word, alpha, beta, d)
+		if !ok {
+			continue
+		}
+
+		length := params[0] + params[1] + params[2]
+		if length < bestLength {
+			bestLength = length
+			best = &dubinsPath{
+				start:        start,
+				startHeading: startHeading,
+				radius:       radius,
+				word:         dubinsWords[word],
+				params:       params}
+		}
+	}
+
+	return best
+}
+
+// Length returns the length of the curve in pixels
+func (d *dubinsPath) Length() float64 {
+	return (d.params[0] + d.params[1] + d.params[2]) * d.radius
+}
+
+// advance moves a unit radius pose along a single segment
+func advance(x, y, heading, t float64, segment dubinsSegment) (float64, float64, float64) {
+	switch segment {
+	case leftTurn:
+		return x + math.Sin(heading+t) - math.Sin(heading), y - math.Cos(heading+t) + math.Cos(heading), heading + t
+	case rightTurn:
+		return x - math.Sin(heading-t) + math.Sin(heading), y + math.Cos(heading-t) - math.Cos(heading), heading - t
+	default:
+		return x + math.Cos(heading)*t, y + math.Sin(heading)*t, heading
+	}
+}
+
+// Sample returns the pose at distance along the curve
+func (d *dubinsPath) Sample(distance float64) (geom.Coord, float64) {
+	t := distance / d.radius
+
+	x, y, heading := 0.0, 0.0, d.startHeading
+	for i, segment := range d.word {
+		if t <= d.params[i] || i == len(d.word)-1 {
+			x, y, heading = advance(x, y, heading, math.Min(t, d.params[i]), segment)
+			break
+		}
+		x, y, heading = advance(x, y, heading, d.params[i], segment)
+		t -= d.params[i]
+	}
+
+	return geom.Coord{X: x*d.radius + d.start.X, Y: y*d.radius + d.start.Y}, mod2pi(heading)
+}
+
+// Densify samples the curve every step pixels, including both ends
+func (d *dubinsPath) Densify(step float64) []*geom.Coord {
+	length := d.Length()
+	points := []*geom.Coord{}
+	for distance := 0.0; distance < length; distance += step {
+		point, _ := d.Sample(distance)
+		points = append(points, &point)
+	}
+
+	end, _ := d.Sample(length)
+	return append(points, &end)
+}
@@ -0,0 +1,90 @@
+package rrtstar
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/skelterjohn/geom"
+)
+
+// headingDifference is how far apart two headings are, either way round
+func headingDifference(a, b float64) float64 {
+	d := mod2pi(a - b)
+	return math.Min(d, 2*math.Pi-d)
+}
+
+func TestEveryDubinsWordReachesTheEndPose(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	const radius = 5.0
+	var reached [6]int
+	for i := 0; i < 2000; i++ {
+		// half the poses close enough together for the three turn words to connect them
+		spread := 100.0
+		if i%2 == 0 {
+			spread = 3 * radius
+		}
+		start := geom.Coord{X: random.Float64() * spread, Y: random.Float64() * spread}
+		end := geom.Coord{X: random.Float64() * spread, Y: random.Float64() * spread}
+		startHeading, endHeading := random.Float64()*2*math.Pi, random.Float64()*2*math.Pi
+
+		dx, dy := end.X-start.X, end.Y-start.Y
+		theta := mod2pi(math.Atan2(dy, dx))
+		alpha, beta := mod2pi(startHeading-theta), mod2pi(endHeading-theta)
+		shortest := shortestDubinsPath(start, startHeading, end, endHeading, radius)
+
+		for word := range dubinsWords {
+			params, ok := dubinsWordParams(word, alpha, beta, math.Sqrt(dx*dx+dy*dy)/radius)
+			if !ok {
+				continue
+			}
+			reached[word]++
+
+			path := &dubinsPath{start: start, startHeading: startHeading, radius: radius, word: dubinsWords[word], params: params}
+			point, heading := path.Sample(path.Length())
+			if euclideanDistance(&point, &end) > 1e-6 || headingDifference(heading, endHeading) > 1e-6 {
+				t.Fatalf("word %d from %v facing %f ends at %v facing %f, not %v facing %f", word, start, startHeading, point, heading, end, endHeading)
+			}
+			if path.Length() < shortest.Length()-1e-9 {
+				t.Fatalf("word %d is %f long but the shortest path found is %f", word, path.Length(), shortest.Length())
+			}
+		}
+		if shortest.Length() < euclideanDistance(&start, &end)-1e-9 {
+			t.Fatalf("a path of %f is shorter than the straight line between its ends", shortest.Length())
+		}
+	}
+
+	for word, count := range reached {
+		if count == 0 {
+			t.Errorf("word %d never connected a pair of poses", word)
+		}
+	}
+}
+
+func TestDubinsPathLengths(t *testing.T) {
+	for _, test := range []struct {
+		name                     string
+		end                      geom.Coord
+		startHeading, endHeading float64
+		length                   float64
+	}{
+		{"straight ahead", geom.Coord{X: 30, Y: 0}, 0, 0, 30},
+		{"half turn to the left", geom.Coord{X: 0, Y: 10}, 0, math.Pi, 5 * math.Pi},
+		{"half turn to the right", geom.Coord{X: 0, Y: -10}, 0, math.Pi, 5 * math.Pi},
+		{"quarter turn and on", geom.Coord{X: 5, Y: 25}, 0, math.Pi / 2, 5*math.Pi/2 + 20},
+		{"turn around on the spot", geom.Coord{}, 0, math.Pi, 5 * 7 * math.Pi / 3},
+	} {
+		path := shortestDubinsPath(geom.Coord{}, test.startHeading, test.end, test.endHeading, 5)
+		if path == nil {
+			t.Errorf("%s: no path", test.name)
+			continue
+		}
+		if math.Abs(path.Length()-test.length) > 1e-6 {
+			t.Errorf("%s: %f long, expected %f", test.name, path.Length(), test.length)
+		}
+		points := path.Densify(1)
+		if *points[0] != (geom.Coord{}) || euclideanDistance(points[len(points)-1], &test.end) > 1e-6 {
+			t.Errorf("%s: densified from %v to %v", test.name, *points[0], *points[len(points)-1])
+		}
+	}
+}
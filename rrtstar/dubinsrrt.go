@@ -0,0 +1,259 @@
+package rrtstar
+
+import (
+	"errors"
+	"math"
+
+	"github.com/dhconnelly/rtreego"
+	"github.com/skelterjohn/geom"
+)
+
+// pathResolution is how far apart points are placed when a curve is drawn or collision checked
+const pathResolution = 1.0
+
+// DubinsRrtStar is an rrt* for vehicles with a minimum turning radius. Nodes carry a heading and
+// are joined by dubins curves instead of straight lines
+type DubinsRrtStar struct {
+	PlannerBase
+	turningRadius float64
+}
+
+// NewDubinsRrtStar creates a new rrt star that steers with dubins curves
+func NewDubinsRrtStar(obstacleMap ObstacleMap, obstacleRects []*geom.Rect, config PlannerConfig) (*DubinsRrtStar, error) {
+	if config.TurningRadius <= 0 {
+		return nil, errors.New("dubins planning needs a positive turning radius")
+	}
+
+	base, err := newPlannerBase(obstacleMap, obstacleRects, config)
+	if err != nil {
+		return nil, err
+	}
+
+	dubinsRrtStar := &DubinsRrtStar{PlannerBase: base, turningRadius: config.TurningRadius}
+	dubinsRrtStar.nodeThreshold = uint64(config.NodeDensity * float64(config.Width*config.Height))
+
+	dubinsRrtStar.Root = &Node{parent: nil, Coord: *dubinsRrtStar.StartPoint, CumulativeCost: 0, Heading: config.StartHeading}
+	dubinsRrtStar.rtree.Insert(dubinsRrtStar.Root)
+	dubinsRrtStar.NumNodes = 1
+
+	dubinsRrtStar.Root.UnseenArea = dubinsRrtStar.getPointCost(dubinsRrtStar.StartPoint)
+
+	return dubinsRrtStar, nil
+}
+
+func (d *DubinsRrtStar) curveBetween(from *Node, to geom.Coord, toHeading float64) *dubinsPath {
+	return shortestDubinsPath(from.Coord, from.Heading, to, toHeading, d.turningRadius)
+}
+
+func (d *DubinsRrtStar) curveIntersectsObstacle(curve *dubinsPath) bool {
+	points := curve.Densify(pathResolution)
+	for i := 1; i < len(points); i++ {
+		if d.lineIntersectsObstacle(*points[i-1], *points[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// getCurveCost runs the cost function over short chords of the curve
+func (d *DubinsRrtStar) getCurveCost(curve *dubinsPath) float64 {
+	points := curve.Densify(d.maxSegment / 4.0)
+	cost := 0.0
+	for i := 1; i < len(points); i++ {
+		cost += d.getCost(points[i-1], points[i])
+	}
+	return cost
+}
+
+func (d *DubinsRrtStar) neighborsOf(point geom.Coord, neighborhoodSize float64) []*Node {
+	rtreePoint := rtreego.Point{point.X, point.Y}
+	spatialNeighbors := d.rtree.SearchIntersect(rtreePoint.ToRect(neighborhoodSize))
+	neighbors := make([]*Node, 0, len(spatialNeighbors))
+	for _, spatialNeighbor := range spatialNeighbors {
+		neighbor := spatialNeighbor.(*Node)
+		if neighbor.Coord != point {
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors
+}
+
+// getBestParent finds the neighbor with the cheapest collision free curve to the pose
+func (d *DubinsRrtStar) getBestParent(neighbors []*Node, point geom.Coord, heading float64) (*Node, float64) {
+	bestCost := math.MaxFloat64
+	bestCumulativeCost := math.MaxFloat64
+	var bestParent *Node
+	for _, neighbor := range neighbors {
+		curve := d.curveBetween(neighbor, point, heading)
+		if curve == nil || curve.Length() > d.rewireNeighborhood*2 || d.curveIntersectsObstacle(curve) {
+			continue
+		}
+
+		cost := d.getCurveCost(curve)
+		if cost+neighbor.CumulativeCost < bestCumulativeCost {
+			bestCost = cost
+			bestCumulativeCost = cost + neighbor.CumulativeCost
+			bestParent = neighbor
+		}
+	}
+
+	return bestParent, bestCost
+}
+
+// rewireThrough gives each neighbor a curve from node if that's cheaper than its current one
+func (d *DubinsRrtStar) rewireThrough(node *Node, neighbors []*Node) {
+	for _, neighbor := range neighbors {
+		if neighbor == node || neighbor == node.parent {
+			continue
+		}
+
+		curve := d.curveBetween(node, neighbor.Coord, neighbor.Heading)
+		if curve == nil || curve.Length() > d.rewireNeighborhood*2 || d.curveIntersectsObstacle(curve) {
+			continue
+		}
+
+		cost := d.getCurveCost(curve)
+		if cost+node.CumulativeCost < neighbor.CumulativeCost {
+			neighbor.Rewire(node, cost)
+		}
+	}
+}
+
+func (d *DubinsRrtStar) sampleWithNewNode() {
+	d.extendTree(d.rtree, d.nextSamplePoint())
+}
+
+// extendTree steers from the nearest node in rtree toward point and adds a node there facing away
+// from it. It returns nil if no node could be added
+func (d *DubinsRrtStar) extendTree(rtree *rtreego.Rtree, point geom.Coord) *Node {
+	nnSpatial := rtree.NearestNeighbor(rtreego.Point{point.X, point.Y})
+	nn := nnSpatial.(*Node)
+
+	// the new node faces away from the node it grew from
+	heading := angleBetweenPoints(nn.Coord, point)
+	if euclideanDistance(&nn.Coord, &point) > d.maxSegment {
+		point = geom.Coord{X: d.maxSegment*math.Cos(heading) + nn.Coord.X, Y: d.maxSegment*math.Sin(heading) + nn.Coord.Y}
+	}
+
+	if d.obstacleMap.PointIntersects(point) {
+		return nil
+	}
+
+	neighbors := d.neighborsOf(point, d.rewireNeighborhood)
+	bestParent, bestCost := d.getBestParent(neighbors, point, heading)
+	if bestParent == nil {
+		return nil
+	}
+
+	newNode := bestParent.AddAndCreateChild(point, bestCost, 0.0)
+	newNode.Heading = heading
+	d.NumNodes++
+	rtree.Insert(newNode)
+
+	d.rewireThrough(newNode, neighbors)
+	return newNode
+}
+
+func (d *DubinsRrtStar) sampleWithoutNewNode() {
+	point := d.nextSamplePoint()
+	neighbors := d.neighborsOf(point, d.rewireNeighborhood)
+
+	var bestNeighbor *Node
+	for _, neighbor := range neighbors {
+		if bestNeighbor == nil || neighbor.CumulativeCost < bestNeighbor.CumulativeCost {
+			bestNeighbor = neighbor
+		}
+	}
+
+	if bestNeighbor != nil {
+		d.rewireThrough(bestNeighbor, neighbors)
+	}
+}
+
+// goalEdges are curveEdges that arrive at the goal heading straight at it, since the goal doesn't
+// care which way the vehicle faces
+func (d *DubinsRrtStar) goalEdges() treeEdges {
+	edges := d.curveEdges()
+	face := func(parent, child *Node) {
+		if child == d.endNode {
+			child.Heading = angleBetweenPoints(parent.Coord, child.Coord)
+		}
+	}
+
+	isFree, cost, attach := edges.isFree, edges.cost, edges.attach
+	edges.isFree = func(parent, child *Node) bool {
+		face(parent, child)
+		return isFree(parent, child)
+	}
+	edges.cost = func(parent, child *Node) float64 {
+		face(parent, child)
+		return cost(parent, child)
+	}
+	edges.attach = func(parent, child *Node, edgeCost float64) {
+		face(parent, child)
+		attach(parent, child, edgeCost)
+	}
+	return edges
+}
+
+func (d *DubinsRrtStar) refreshBestPath() {
+	if !d.isGoalReachable() {
+		d.joinGoal(d.rtree, d.goalEdges(), d.rewireNeighborhood)
+	}
+	d.traceCurvePath()
+}
+
+// traceCurvePath fills BestPath with the densified curves from the end back to the start
+func (d *DubinsRrtStar) traceCurvePath() {
+	d.BestPath = d.BestPath[:0]
+	if !d.isGoalReachable() {
+		return
+	}
+	for currentNode := d.endNode; currentNode.parent != nil; currentNode = currentNode.parent {
+		curve := d.curveBetween(currentNode.parent, currentNode.Coord, currentNode.Heading)
+		points := curve.Densify(2 * pathResolution)
+		// walk the curve backwards and leave the parent for the next edge
+		for i := len(points) - 1; i > 0; i-- {
+			d.BestPath = append(d.BestPath, points[i])
+		}
+	}
+	d.BestPath = append(d.BestPath, &d.Root.Coord)
+}
+
+// moveStartPoint takes the root to the moved start without adding a node
+func (d *DubinsRrtStar) moveStartPoint(dx, dy float64) {
+	if dx != 0 || dy != 0 {
+		d.StartPoint.X += dx
+		d.StartPoint.Y += dy
+
+		d.forgetRemoved(d.moveRoot(*d.StartPoint, d.curveEdges()))
+		d.rewireThrough(d.Root, d.neighborsOf(d.Root.Coord, d.rewireNeighborhood*1.5))
+		d.refreshBestPath()
+	}
+}
+
+func (d *DubinsRrtStar) moveEndPoint(dx, dy float64) {
+	if dx != 0 || dy != 0 {
+		d.EndPoint.X += dx
+		d.EndPoint.Y += dy
+		d.trackGoal(d.rtree, d.goalEdges(), d.extendTree)
+		d.traceCurvePath()
+	}
+}
+
+func (d *DubinsRrtStar) bestPathCost() float64 {
+	if !d.isGoalReachable() {
+		return math.Inf(1)
+	}
+	return d.endNode.CumulativeCost
+}
+
+func (d *DubinsRrtStar) sample() {
+	d.IsAddingNodes = d.NumNodes < d.nodeThreshold
+	if d.IsAddingNodes {
+		d.sampleWithNewNode()
+	} else {
+		d.sampleWithoutNewNode()
+	}
+	d.refreshBestPath()
+}
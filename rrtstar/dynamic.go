@@ -0,0 +1,850 @@
+package rrtstar
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/dhconnelly/rtreego"
+	"github.com/skelterjohn/geom"
+)
+
+// Obstacle is a *geom.Rect, a Polygon or a *Circle that's added to or removed from the map while planning.
+// Obstacles are removed by matching their shape, so a copy of one removes it as well as the original
+type Obstacle interface{}
+
+// MapCostFunction is a CostFunction that depends on the obstacles, so it has to be told when they change
+type MapCostFunction interface {
+	CostFunction
+	LoadMap(width, height int, obstacleRects []*geom.Rect, obstaclePolygons []Polygon, obstacleCircles []*Circle) error
+}
+
+func obstacleBounds(obstacle Obstacle) (geom.Rect, error) {
+	switch o := obstacle.(type) {
+	case *geom.Rect:
+		return *o, nil
+	case Polygon:
+		return o.Bounds(), nil
+	case *Circle:
+		return o.Bounds(), nil
+	}
+	return geom.Rect{}, fmt.Errorf("can't use a %T as an obstacle", obstacle)
+}
+
+// withObstacle returns the obstacle lists with obstacle added. The lists are copied rather than
+// appended to, since planners and maps share them
+func withObstacle(rects []*geom.Rect, polygons []Polygon, circles []*Circle, obstacle Obstacle) ([]*geom.Rect, []Polygon, []*Circle) {
+	switch o := obstacle.(type) {
+	case *geom.Rect:
+		rects = append(rects[:len(rects):len(rects)], o)
+	case Polygon:
+		polygons = append(polygons[:len(polygons):len(polygons)], o)
+	case *Circle:
+		circles = append(circles[:len(circles):len(circles)], o)
+	}
+	return rects, polygons, circles
+}
+
+// withoutObstacle returns copies of the obstacle lists without the first obstacle shaped like obstacle,
+// and whether there was one
+func withoutObstacle(rects []*geom.Rect, polygons []Polygon, circles []*Circle, obstacle Obstacle) ([]*geom.Rect, []Polygon, []*Circle, bool) {
+	switch o := obstacle.(type) {
+	case *geom.Rect:
+		for i, rect := range rects {
+			if *rect == *o {
+				return append(append([]*geom.Rect{}, rects[:i]...), rects[i+1:]...), polygons, circles, true
+			}
+		}
+	case Polygon:
+		for i, polygon := range polygons {
+			if polygon.equals(o) {
+				return rects, append(append([]Polygon{}, polygons[:i]...), polygons[i+1:]...), circles, true
+			}
+		}
+	case *Circle:
+		for i, circle := range circles {
+			if *circle == *o {
+				return rects, polygons, append(append([]*Circle{}, circles[:i]...), circles[i+1:]...), true
+			}
+		}
+	}
+	return rects, polygons, circles, false
+}
+
+func (p Polygon) equals(other Polygon) bool {
+	if len(p) != len(other) {
+		return false
+	}
+	for i, vertex := range p {
+		if *vertex != *other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// WithObstacle returns a copy of the map with obstacle added, inflated as much as the rest
+func (v *VectorObstacleMap) WithObstacle(obstacle Obstacle) (*VectorObstacleMap, error) {
+	if _, err := obstacleBounds(obstacle); err != nil {
+		return nil, err
+	}
+	updated := *v
+	updated.Rects, updated.Polygons, updated.Circles = withObstacle(v.Rects, v.Polygons, v.Circles, obstacle)
+	return &updated, nil
+}
+
+// WithoutObstacle returns a copy of the map with obstacle taken away
+func (v *VectorObstacleMap) WithoutObstacle(obstacle Obstacle) (*VectorObstacleMap, error) {
+	if _, err := obstacleBounds(obstacle); err != nil {
+		return nil, err
+	}
+	updated := *v
+	var found bool
+	if updated.Rects, updated.Polygons, updated.Circles, found = withoutObstacle(v.Rects, v.Polygons, v.Circles, obstacle); !found {
+		return nil, errors.New("obstacle isn't on the map")
+	}
+	return &updated, nil
+}
+
+// WithObstacle returns a copy of the map with obstacle painted on, inflated as much as the rest
+func (r *RasterObstacleMap) WithObstacle(obstacle Obstacle) (*RasterObstacleMap, error) {
+	bounds, err := obstacleBounds(obstacle)
+	if err != nil {
+		return nil, err
+	}
+	updated := r.withImageCopy()
+	updated.paintedRects, updated.paintedPolygons, updated.paintedCircles = withObstacle(r.paintedRects, r.paintedPolygons, r.paintedCircles, obstacle)
+	updated.repaint(bounds, false)
+	return updated, nil
+}
+
+// WithoutObstacle returns a copy of the map with a painted obstacle cleared off. Obstacles that came
+// with the image can't be told apart from each other, so they stay
+func (r *RasterObstacleMap) WithoutObstacle(obstacle Obstacle) (*RasterObstacleMap, error) {
+	bounds, err := obstacleBounds(obstacle)
+	if err != nil {
+		return nil, err
+	}
+	updated := r.withImageCopy()
+	var found bool
+	if updated.paintedRects, updated.paintedPolygons, updated.paintedCircles, found = withoutObstacle(r.paintedRects, r.paintedPolygons, r.paintedCircles, obstacle); !found {
+		return nil, errors.New("obstacle wasn't added to the map")
+	}
+	updated.repaint(bounds, true)
+	return updated, nil
+}
+
+func (r *RasterObstacleMap) withImageCopy() *RasterObstacleMap {
+	updated := *r
+	if updated.base == nil {
+		// the image is never changed in place, so it can be shared as the base
+		updated.base = r.Image
+	}
+	updated.Image = image.NewGray(r.Image.Bounds())
+	copy(updated.Image.Pix, r.Image.Pix)
+	return &updated
+}
+
+// repaint paints the painted obstacles over the pixels around region, first putting back the base
+// image there if clear is set. A pixel is painted if an obstacle, inflated as much as the image,
+// touches any of it
+func (r *RasterObstacleMap) repaint(region geom.Rect, clear bool) {
+	reach := r.radius + math.Sqrt2/2
+	inflateRectangle(&region, reach+1)
+	pixels := image.Rect(int(math.Floor(region.Min.X)), int(math.Floor(region.Min.Y)), int(math.Ceil(region.Max.X)), int(math.Ceil(region.Max.Y))).Intersect(r.Image.Bounds())
+
+	imageBounds := r.Image.Bounds()
+	shapes := NewVectorObstacleMap(imageBounds.Max.X, imageBounds.Max.Y, r.paintedRects, r.paintedPolygons, r.paintedCircles).Inflate(reach)
+	for y := pixels.Min.Y; y < pixels.Max.Y; y++ {
+		for x := pixels.Min.X; x < pixels.Max.X; x++ {
+			i := r.Image.PixOffset(x, y)
+			if clear {
+				r.Image.Pix[i] = r.base.Pix[r.base.PixOffset(x, y)]
+			}
+			if shapes.PointIntersects(geom.Coord{X: float64(x) + 0.5, Y: float64(y) + 0.5}) {
+				r.Image.Pix[i] = 255
+			}
+		}
+	}
+}
+
+// LoadMap switches the cost over to new obstacles. When they're a change to the old ones, a precomputed
+// field is only swept again where the change can be seen. That field belongs to this cost alone, since
+// no other planner's map has the change. A different map gets the shared field for it
+func (c *UnseenAreaCost) LoadMap(width, height int, obstacleRects []*geom.Rect, obstaclePolygons []Polygon, obstacleCircles []*Circle) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.Field != nil {
+		sameSize := c.mapArea == float64(width*height) &&
+			c.Field.Columns == int(math.Ceil(float64(width)/c.Field.Spacing)) && c.Field.Rows == int(math.Ceil(float64(height)/c.Field.Spacing))
+		keptRects, keptPolygons, keptCircles, changed, anyChanged := obstacleChanges(c.obstacleRects, c.obstaclePolygons, c.obstacleCircles, obstacleRects, obstaclePolygons, obstacleCircles)
+		if sameSize && anyChanged {
+			c.Field = c.Field.updated(width, height, obstacleRects, obstaclePolygons, obstacleCircles, keptRects, keptPolygons, keptCircles, changed, c.obstacleArea, c.Sensor)
+		} else if !sameSize {
+			field, err := SharedVisibilityField(width, height, obstacleRects, obstaclePolygons, obstacleCircles, c.Sensor, c.Field.Spacing, c.fieldCacheDir)
+			if err != nil {
+				return err
+			}
+			c.Field = field
+		}
+	}
+
+	c.mapArea = float64(width * height)
+	c.obstacleArea = obstaclesArea(obstacleRects, obstaclePolygons, obstacleCircles)
+	c.obstacleRects, c.obstaclePolygons, c.obstacleCircles = obstacleRects, obstaclePolygons, obstacleCircles
+	c.views.clear()
+	c.viewshed.LoadMap(float64(width), float64(height), 0, obstacleRects, polygonOutlines(obstaclePolygons), circleBlocks(obstacleCircles), nil)
+	return nil
+}
+
+// obstacleChanges sorts the obstacles into the ones in both maps and the ones only in one of them,
+// returning the bounds around all of the latter and whether there were any
+func obstacleChanges(oldRects []*geom.Rect, oldPolygons []Polygon, oldCircles []*Circle, rects []*geom.Rect, polygons []Polygon, circles []*Circle) (
+	keptRects []*geom.Rect, keptPolygons []Polygon, keptCircles []*Circle, changed geom.Rect, anyChanged bool) {
+	addChange := func(obstacle Obstacle) {
+		bounds, _ := obstacleBounds(obstacle)
+		if !anyChanged {
+			changed, anyChanged = bounds, true
+			return
+		}
+		changed.Min.X, changed.Min.Y = math.Min(changed.Min.X, bounds.Min.X), math.Min(changed.Min.Y, bounds.Min.Y)
+		changed.Max.X, changed.Max.Y = math.Max(changed.Max.X, bounds.Max.X), math.Max(changed.Max.Y, bounds.Max.Y)
+	}
+
+	// whatever's left of the old obstacles once the new ones are taken out of them was removed
+	remainingRects, remainingPolygons, remainingCircles := oldRects, oldPolygons, oldCircles
+	var found bool
+	for _, obstacle := range obstacleList(rects, polygons, circles) {
+		if remainingRects, remainingPolygons, remainingCircles, found = withoutObstacle(remainingRects, remainingPolygons, remainingCircles, obstacle); found {
+			keptRects, keptPolygons, keptCircles = withObstacle(keptRects, keptPolygons, keptCircles, obstacle)
+		} else {
+			addChange(obstacle)
+		}
+	}
+	for _, obstacle := range obstacleList(remainingRects, remainingPolygons, remainingCircles) {
+		addChange(obstacle)
+	}
+	return
+}
+
+func obstacleList(rects []*geom.Rect, polygons []Polygon, circles []*Circle) []Obstacle {
+	var obstacles []Obstacle
+	for _, rect := range rects {
+		obstacles = append(obstacles, rect)
+	}
+	for _, polygon := range polygons {
+		obstacles = append(obstacles, polygon)
+	}
+	for _, circle := range circles {
+		obstacles = append(obstacles, circle)
+	}
+	return obstacles
+}
+
+// updateObstacles adds obstacle to or takes it away from the obstacle map, the viewshed and the cost
+// function. It returns the area where edges may have changed and leaves the trees for the planners to repair
+func (p *PlannerBase) updateObstacles(obstacle Obstacle, add bool) (geom.Rect, error) {
+	var updatedMap ObstacleMap
+	var radius float64
+	var err error
+	switch obstacleMap := p.obstacleMap.(type) {
+	case *VectorObstacleMap:
+		var updated *VectorObstacleMap
+		if add {
+			updated, err = obstacleMap.WithObstacle(obstacle)
+		} else {
+			updated, err = obstacleMap.WithoutObstacle(obstacle)
+		}
+		updatedMap, radius = updated, obstacleMap.radius
+	case *RasterObstacleMap:
+		var updated *RasterObstacleMap
+		if add {
+			updated, err = obstacleMap.WithObstacle(obstacle)
+		} else {
+			updated, err = obstacleMap.WithoutObstacle(obstacle)
+		}
+		// painting reaches into every pixel the inflated obstacle touches
+		updatedMap, radius = updated, obstacleMap.radius+math.Sqrt2
+	default:
+		return geom.Rect{}, fmt.Errorf("can't change the obstacles of a %T", p.obstacleMap)
+	}
+	if err != nil {
+		return geom.Rect{}, err
+	}
+	if add && (updatedMap.PointIntersects(*p.StartPoint) || updatedMap.PointIntersects(*p.EndPoint)) {
+		return geom.Rect{}, errors.New("obstacle would cover the start or end point")
+	}
+
+	// the viewshed's obstacles were passed in separately, so they may not hold this one
+	rects, polygons, circles := p.obstacleRects, p.obstaclePolygons, p.obstacleCircles
+	if add {
+		rects, polygons, circles = withObstacle(rects, polygons, circles, obstacle)
+	} else {
+		rects, polygons, circles, _ = withoutObstacle(rects, polygons, circles, obstacle)
+	}
+
+	if mapCost, ok := p.costFunction.(MapCostFunction); ok {
+		if err := mapCost.LoadMap(p.width, p.height, rects, polygons, circles); err != nil {
+			return geom.Rect{}, err
+		}
+	}
+
+	p.obstacleMap = updatedMap
+	p.obstacleRects, p.obstaclePolygons, p.obstacleCircles = rects, polygons, circles
+
+	center := p.Viewshed.Center
+	p.Viewshed.LoadMap(float64(p.width), float64(p.height), 0, rects, polygonOutlines(polygons), circleBlocks(circles), nil)
+	p.Viewshed.UpdateCenterLocation(center.X, center.Y)
+
+	if p.coverage != nil {
+		p.coverage = newCoverageGrid(p.width, p.height, rects, polygons, circles, p.coverage.cellSize)
+	}
+
+	region, _ := obstacleBounds(obstacle)
+	inflateRectangle(&region, radius)
+	return region, nil
+}
+
+// costsFollowMap reports whether edge costs depend on the obstacles, so the tree has to be costed
+// again whenever they change
+func (p *PlannerBase) costsFollowMap() bool {
+	_, ok := p.costFunction.(MapCostFunction)
+	return ok
+}
+
+// edgeReach is longer than any edge the planners make. Rewiring around a new root reaches 1.5 times
+// across the corner of the neighborhood and a dubins curve can be twice as long as the neighborhood
+func (p *PlannerBase) edgeReach() float64 {
+	return 2.5 * p.rewireNeighborhood
+}
+
+// nodesAround returns the nodes in rtree close enough to region for their edge to a parent to cross it
+func (p *PlannerBase) nodesAround(rtree *rtreego.Rtree, region geom.Rect) []*Node {
+	center := rtreego.Point{(region.Min.X + region.Max.X) / 2, (region.Min.Y + region.Max.Y) / 2}
+	halfSize := math.Max(region.Width(), region.Height())/2 + p.edgeReach()
+
+	spatialNodes := rtree.SearchIntersect(center.ToRect(halfSize))
+	nodes := make([]*Node, len(spatialNodes))
+	for i, spatialNode := range spatialNodes {
+		nodes[i] = spatialNode.(*Node)
+	}
+	return nodes
+}
+
+// treeEdges is how a planner joins its nodes, so any planner's tree can be repaired the same way
+type treeEdges struct {
+	// isFree reports whether the edge from parent to child misses every obstacle
+	isFree func(parent, child *Node) bool
+	// cost is the cost of the edge from parent to child
+	cost func(parent, child *Node) float64
+	// attach moves child under parent with an edge that costs cost
+	attach func(parent, child *Node, cost float64)
+	// travel returns the point distance along the edge from parent to child and how long the edge is
+	travel func(parent, child *Node, distance float64) (geom.Coord, float64)
+}
+
+// straightEdges joins nodes with straight lines costed by the cost function
+func (p *PlannerBase) straightEdges() treeEdges {
+	return treeEdges{
+		isFree: func(parent, child *Node) bool {
+			return !p.lineIntersectsObstacle(parent.Coord, child.Coord)
+		},
+		cost: func(parent, child *Node) float64 {
+			return p.getCost(&parent.Coord, &child.Coord)
+		},
+		attach: func(parent, child *Node, cost float64) {
+			child.Rewire(parent, cost)
+		},
+		travel: travelStraight}
+}
+
+func travelStraight(parent, child *Node, distance float64) (geom.Coord, float64) {
+	length := euclideanDistance(&parent.Coord, &child.Coord)
+	if length == 0 {
+		return child.Coord, 0
+	}
+	t := math.Min(distance/length, 1)
+	return geom.Coord{X: parent.X + t*(child.X-parent.X), Y: parent.Y + t*(child.Y-parent.Y)}, length
+}
+
+// reconnection is a way to join an orphaned node back onto the tree
+type reconnection struct {
+	orphan *Node
+	parent *Node
+	cost   float64
+	total  float64 // the orphan's cumulative cost once it's joined
+}
+
+// reconnections is a heap of reconnections with the cheapest on top
+type reconnections []reconnection
+
+func (r reconnections) Len() int            { return len(r) }
+func (r reconnections) Less(i, j int) bool  { return r[i].total < r[j].total }
+func (r reconnections) Swap(i, j int)       { r[i], r[j] = r[j], r[i] }
+func (r *reconnections) Push(x interface{}) { *r = append(*r, x.(reconnection)) }
+func (r *reconnections) Pop() interface{} {
+	last := (*r)[len(*r)-1]
+	*r = (*r)[:len(*r)-1]
+	return last
+}
+
+// repairTree cuts every edge of the tree rooted at root that a new obstacle over region blocks and
+// reconnects the orphaned subtrees. Nodes under the obstacle or that can't be reached any more are
+// removed from the tree and rtree, and returned so the planner can forget them
+func (p *PlannerBase) repairTree(rtree *rtreego.Rtree, root *Node, region geom.Rect, edges treeEdges) []*Node {
+	var removed []*Node
+	buried := make(map[*Node]bool)
+	var cut []*Node
+	nodes := p.nodesAround(rtree, region)
+	// an unchecked hop can be longer than any edge, so it may cross region from further away
+	if hop := p.uncheckedHop; hop != nil && hop.parent != nil && rtree == p.rtree {
+		nodes = append(nodes, hop)
+	}
+	for _, node := range nodes {
+		switch {
+		case node == root:
+		case node.parent == nil:
+			// fmt* samples that haven't been reached yet aren't part of the tree or its count
+			if p.obstacleMap.PointIntersects(node.Coord) {
+				rtree.Delete(node)
+				removed = append(removed, node)
+			}
+		case p.obstacleMap.PointIntersects(node.Coord):
+			buried[node] = true
+		case !edges.isFree(node.parent, node):
+			cut = append(cut, node)
+		}
+	}
+
+	// the subtrees below a buried node or a blocked edge are cut off from the root
+	var orphanRoots []*Node
+	for node := range buried {
+		for _, child := range node.Children {
+			if !buried[child] {
+				child.parent = nil
+				orphanRoots = append(orphanRoots, child)
+			}
+		}
+		removed = append(removed, p.removeNode(rtree, node))
+	}
+	for _, node := range cut {
+		if node.parent != nil {
+			node.parent.RemoveChild(node)
+			node.parent = nil
+			orphanRoots = append(orphanRoots, node)
+		}
+	}
+
+	return append(removed, p.reconnectOrphans(rtree, root, orphanRoots, edges)...)
+}
+
+// removeNode takes a node out of the tree and rtree and returns it
+func (p *PlannerBase) removeNode(rtree *rtreego.Rtree, node *Node) *Node {
+	if node.parent != nil {
+		node.parent.RemoveChild(node)
+		node.parent = nil
+	}
+	node.Children = nil
+	rtree.Delete(node)
+	p.NumNodes--
+	return node
+}
+
+// reconnectOrphans joins the subtrees below orphanRoots, which have been cut from the tree rooted at
+// root, back onto it cheapest first so each one can build on those before it. Nodes that can't be
+// reached any more are removed from the tree and rtree and returned
+func (p *PlannerBase) reconnectOrphans(rtree *rtreego.Rtree, root *Node, orphanRoots []*Node, edges treeEdges) []*Node {
+	// whatever's left is walled off from the root
+	var removed []*Node
+	for orphan := range p.rejoinOrphans(rtree, root, orphanRoots, edges) {
+		removed = append(removed, p.removeNode(rtree, orphan))
+	}
+	return removed
+}
+
+// rejoinOrphans joins what it can of the subtrees below orphanRoots back onto the tree rooted at root.
+// It returns the nodes it couldn't, which are left hanging where they were
+func (p *PlannerBase) rejoinOrphans(rtree *rtreego.Rtree, root *Node, orphanRoots []*Node, edges treeEdges) map[*Node]bool {
+	orphaned := make(map[*Node]bool)
+	var markOrphaned func(node *Node)
+	markOrphaned = func(node *Node) {
+		orphaned[node] = true
+		for _, child := range node.Children {
+			markOrphaned(child)
+		}
+	}
+	for _, node := range orphanRoots {
+		markOrphaned(node)
+	}
+
+	neighborsOf := func(node *Node) []*Node {
+		rtreePoint := rtreego.Point{node.X, node.Y}
+		spatialNeighbors := rtree.SearchIntersect(rtreePoint.ToRect(p.rewireNeighborhood))
+		neighbors := make([]*Node, len(spatialNeighbors))
+		for i, spatialNeighbor := range spatialNeighbors {
+			neighbors[i] = spatialNeighbor.(*Node)
+		}
+		return neighbors
+	}
+
+	// each orphan is only offered a parent it hasn't been offered before, and only queued again when
+	// that's cheaper than the best way back it has already got
+	var queue reconnections
+	cheapest := make(map[*Node]float64)
+	offer := func(orphan, parent *Node) {
+		if parent == orphan || orphaned[parent] || parent != root && parent.parent == nil {
+			return
+		}
+		best, offered := cheapest[orphan]
+		if offered && parent.CumulativeCost >= best || !edges.isFree(parent, orphan) {
+			return
+		}
+		cost := edges.cost(parent, orphan)
+		if offered && parent.CumulativeCost+cost >= best {
+			return
+		}
+		cheapest[orphan] = parent.CumulativeCost + cost
+		heap.Push(&queue, reconnection{orphan: orphan, parent: parent, cost: cost, total: parent.CumulativeCost + cost})
+	}
+	for orphan := range orphaned {
+		for _, neighbor := range neighborsOf(orphan) {
+			offer(orphan, neighbor)
+		}
+	}
+
+	for queue.Len() > 0 {
+		next := heap.Pop(&queue).(reconnection)
+		if !orphaned[next.orphan] {
+			continue
+		}
+		edges.attach(next.parent, next.orphan, next.cost)
+
+		// everything below the orphan comes back with it and may be a way back for the orphans around it
+		var rescued []*Node
+		var rescue func(node *Node)
+		rescue = func(node *Node) {
+			delete(orphaned, node)
+			rescued = append(rescued, node)
+			for _, child := range node.Children {
+				rescue(child)
+			}
+		}
+		rescue(next.orphan)
+
+		for _, node := range rescued {
+			for _, neighbor := range neighborsOf(node) {
+				if orphaned[neighbor] {
+					offer(neighbor, node)
+				}
+			}
+		}
+	}
+
+	return orphaned
+}
+
+// improveTree lets the nodes around region, where an obstacle has gone, offer their neighbors a
+// cheaper way through. The cheapest go first so an improvement can spread out from the gap
+func (p *PlannerBase) improveTree(rtree *rtreego.Rtree, root *Node, region geom.Rect, edges treeEdges) {
+	var nodes []*Node
+	for _, node := range p.nodesAround(rtree, region) {
+		if node == root || node.parent != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].CumulativeCost < nodes[j].CumulativeCost })
+
+	for _, node := range nodes {
+		p.rewireNeighbors(rtree, root, node, edges)
+	}
+}
+
+// rewireNeighbors moves the neighbors of node under it where that's cheaper and returns the neighbors
+// that are part of the tree rooted at root
+func (p *PlannerBase) rewireNeighbors(rtree *rtreego.Rtree, root *Node, node *Node, edges treeEdges) []*Node {
+	var neighbors []*Node
+	rtreePoint := rtreego.Point{node.X, node.Y}
+	for _, spatialNeighbor := range rtree.SearchIntersect(rtreePoint.ToRect(p.rewireNeighborhood)) {
+		neighbor := spatialNeighbor.(*Node)
+		if neighbor == node || neighbor == root || neighbor.parent == nil {
+			continue
+		}
+		neighbors = append(neighbors, neighbor)
+		if neighbor == node.parent || !edges.isFree(node, neighbor) {
+			continue
+		}
+		if cost := edges.cost(node, neighbor); node.CumulativeCost+cost < neighbor.CumulativeCost {
+			edges.attach(node, neighbor, cost)
+		}
+	}
+	return neighbors
+}
+
+// recostTree works out the cost of every edge below node again, for when the cost function has changed
+func (p *PlannerBase) recostTree(node *Node, edges treeEdges) {
+	for _, child := range node.Children {
+		child.CumulativeCost = node.CumulativeCost + edges.cost(node, child)
+		p.recostTree(child, edges)
+	}
+}
+
+// fixTree repairs or improves the tree rooted at root after an obstacle over region was added or
+// taken away, costing it all again first if the costs depend on the obstacles. It returns the nodes
+// it removed
+func (p *PlannerBase) fixTree(rtree *rtreego.Rtree, root *Node, region geom.Rect, added bool, edges treeEdges) []*Node {
+	var removed []*Node
+	if added {
+		removed = p.repairTree(rtree, root, region, edges)
+	}
+	if p.costsFollowMap() {
+		root.UnseenArea = p.getPointCost(&root.Coord)
+		p.recostTree(root, edges)
+	}
+	if !added {
+		p.improveTree(rtree, root, region, edges)
+	}
+	return removed
+}
+
+// forgetRemoved drops the best path if its end was removed, so the planner looks for a new one, and
+// the node the vehicle was driving toward, so it starts again from the root
+func (p *PlannerBase) forgetRemoved(removed []*Node) {
+	for _, node := range removed {
+		if node == p.endNode {
+			p.endNode = nil
+			p.BestPath = p.BestPath[:0]
+		}
+		if node == p.vehicle.target {
+			p.vehicle.target = nil
+		}
+	}
+}
+
+// AddObstacle puts an obstacle on the map and repairs the tree around it
+func (r *RrtStar) AddObstacle(obstacle Obstacle) error {
+	return r.changeObstacle(obstacle, true)
+}
+
+// RemoveObstacle takes an obstacle off the map and lets the tree make use of the space
+func (r *RrtStar) RemoveObstacle(obstacle Obstacle) error {
+	return r.changeObstacle(obstacle, false)
+}
+
+func (r *RrtStar) changeObstacle(obstacle Obstacle, add bool) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	region, err := r.updateObstacles(obstacle, add)
+	if err != nil {
+		return err
+	}
+	r.forgetRemoved(r.fixTree(r.rtree, r.Root, region, add, r.straightEdges()))
+	r.refreshBestPath()
+	return nil
+}
+
+// AddObstacle puts an obstacle on the map and repairs both trees around it
+func (b *BiRrtStar) AddObstacle(obstacle Obstacle) error {
+	return b.changeObstacle(obstacle, true)
+}
+
+// RemoveObstacle takes an obstacle off the map and lets both trees make use of the space
+func (b *BiRrtStar) RemoveObstacle(obstacle Obstacle) error {
+	return b.changeObstacle(obstacle, false)
+}
+
+func (b *BiRrtStar) changeObstacle(obstacle Obstacle, add bool) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	region, err := b.updateObstacles(obstacle, add)
+	if err != nil {
+		return err
+	}
+
+	removed := make(map[*Node]bool)
+	for _, node := range b.fixTree(b.rtree, b.Root, region, add, b.straightEdges()) {
+		removed[node] = true
+	}
+	for _, node := range b.fixTree(b.goalRtree, b.GoalRoot, region, add, b.edgesIn(b.goalRtree)) {
+		removed[node] = true
+	}
+
+	// bridges from removed nodes or across the obstacle are gone, the rest may cost something else now
+	for start, candidate := range b.bridges {
+		if removed[candidate.start] || removed[candidate.goal] || b.lineIntersectsObstacle(candidate.start.Coord, candidate.goal.Coord) {
+			delete(b.bridges, start)
+			continue
+		}
+		if b.costsFollowMap() {
+			candidate.cost = b.getCost(&candidate.start.Coord, &candidate.goal.Coord)
+		}
+	}
+
+	b.refreshBestPath()
+	return nil
+}
+
+// curveEdges joins nodes with the dubins curve into each node's heading
+func (d *DubinsRrtStar) curveEdges() treeEdges {
+	return treeEdges{
+		isFree: func(parent, child *Node) bool {
+			curve := d.curveBetween(parent, child.Coord, child.Heading)
+			return curve != nil && !d.curveIntersectsObstacle(curve)
+		},
+		cost: func(parent, child *Node) float64 {
+			curve := d.curveBetween(parent, child.Coord, child.Heading)
+			if curve == nil {
+				return math.Inf(1)
+			}
+			return d.getCurveCost(curve)
+		},
+		attach: func(parent, child *Node, cost float64) {
+			child.Rewire(parent, cost)
+		},
+		travel: func(parent, child *Node, distance float64) (geom.Coord, float64) {
+			curve := d.curveBetween(parent, child.Coord, child.Heading)
+			if curve == nil {
+				return travelStraight(parent, child, distance)
+			}
+			point, _ := curve.Sample(math.Min(distance, curve.Length()))
+			return point, curve.Length()
+		}}
+}
+
+// AddObstacle puts an obstacle on the map and repairs the tree around it
+func (d *DubinsRrtStar) AddObstacle(obstacle Obstacle) error {
+	return d.changeObstacle(obstacle, true)
+}
+
+// RemoveObstacle takes an obstacle off the map and lets the tree make use of the space
+func (d *DubinsRrtStar) RemoveObstacle(obstacle Obstacle) error {
+	return d.changeObstacle(obstacle, false)
+}
+
+func (d *DubinsRrtStar) changeObstacle(obstacle Obstacle, add bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	region, err := d.updateObstacles(obstacle, add)
+	if err != nil {
+		return err
+	}
+	d.forgetRemoved(d.fixTree(d.rtree, d.Root, region, add, d.curveEdges()))
+	d.refreshBestPath()
+	return nil
+}
+
+// coverageEdges joins nodes with straight lines costed by what's still unseen along them
+func (c *CoverageRrtStar) coverageEdges() treeEdges {
+	return treeEdges{
+		isFree: func(parent, child *Node) bool {
+			return !c.lineIntersectsObstacle(parent.Coord, child.Coord)
+		},
+		cost: func(parent, child *Node) float64 {
+			return c.edgeCost(parent, child.Coord, c.viewAlong(parent, child.Coord, child.view))
+		},
+		attach: func(parent, child *Node, cost float64) {
+			child.Rewire(parent, cost)
+			c.attach(child, c.viewAlong(parent, child.Coord, child.view))
+		},
+		travel: travelStraight}
+}
+
+// relook sweeps what every node below node sees again
+func (c *CoverageRrtStar) relook(node *Node) {
+	if node.parent == nil {
+		node.view = c.viewFrom(&node.Coord, node.Heading)
+	} else {
+		node.view = c.viewAlong(node.parent, node.Coord, nil)
+	}
+	for _, child := range node.Children {
+		c.relook(child)
+	}
+}
+
+// AddObstacle puts an obstacle on the map and repairs the tree around it. Every node sees something
+// else now, so it sweeps a viewshed for each of them
+func (c *CoverageRrtStar) AddObstacle(obstacle Obstacle) error {
+	return c.changeObstacle(obstacle, true)
+}
+
+// RemoveObstacle takes an obstacle off the map and lets the tree make use of the space. Like
+// AddObstacle, it sweeps a viewshed for every node
+func (c *CoverageRrtStar) RemoveObstacle(obstacle Obstacle) error {
+	return c.changeObstacle(obstacle, false)
+}
+
+func (c *CoverageRrtStar) changeObstacle(obstacle Obstacle, add bool) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	region, err := c.updateObstacles(obstacle, add)
+	if err != nil {
+		return err
+	}
+	c.viewshed = c.Viewshed.Clone()
+
+	// views have to be right before any edge is costed, and costs depend on everything seen before
+	// them, so the whole tree is attached again rather than costed edge by edge
+	c.relook(c.Root)
+	edges := c.coverageEdges()
+	if add {
+		c.forgetRemoved(c.repairTree(c.rtree, c.Root, region, edges))
+	}
+	c.attach(c.Root, c.Root.view)
+	if !add {
+		c.improveTree(c.rtree, c.Root, region, edges)
+	}
+
+	c.refreshBestPath()
+	return nil
+}
+
+// AddObstacle puts an obstacle on the map and repairs the tree around it. Samples under the
+// obstacle are dropped whether they've been reached or not
+func (f *FmtStar) AddObstacle(obstacle Obstacle) error {
+	return f.changeObstacle(obstacle, true)
+}
+
+// RemoveObstacle takes an obstacle off the map and lets the tree make use of the space
+func (f *FmtStar) RemoveObstacle(obstacle Obstacle) error {
+	return f.changeObstacle(obstacle, false)
+}
+
+func (f *FmtStar) changeObstacle(obstacle Obstacle, add bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	region, err := f.updateObstacles(obstacle, add)
+	if err != nil {
+		return err
+	}
+
+	f.forgetRemoved(f.fixTree(f.rtree, f.Root, region, add, f.straightEdges()))
+	f.refreshBestPath()
+	return nil
+}
+
+// forgetRemoved takes removed nodes out of the open set too
+func (f *FmtStar) forgetRemoved(removed []*Node) {
+	gone := make(map[*Node]bool, len(removed))
+	for _, node := range removed {
+		gone[node] = true
+		if node.Status == Open {
+			f.rtreeOpen.Delete(node)
+		}
+	}
+	open := f.open[:0]
+	for _, node := range f.open {
+		if !gone[node] {
+			open = append(open, node)
+		}
+	}
+	f.open = open
+	f.PlannerBase.forgetRemoved(removed)
+}
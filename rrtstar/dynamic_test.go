@@ -0,0 +1,210 @@
+package rrtstar
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/brychanrobot/go-rrt-star/viewshed"
+	"github.com/skelterjohn/geom"
+)
+
+// checkTree walks the tree under root, failing t for any node inside an obstacle, any blocked edge
+// and any cost that doesn't add up, and returns how many nodes it found
+func checkTree(t *testing.T, name string, p *PlannerBase, root *Node, edges treeEdges) int {
+	count := 0
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		count++
+		if p.obstacleMap.PointIntersects(node.Coord) {
+			t.Errorf("%s: node %v is inside an obstacle", name, node.Coord)
+		}
+		for _, child := range node.Children {
+			if child.parent != node {
+				t.Fatalf("%s: %v is a child of %v but its parent is something else", name, child.Coord, node.Coord)
+			}
+			if !edges.isFree(node, child) {
+				t.Errorf("%s: the edge from %v to %v is blocked", name, node.Coord, child.Coord)
+			}
+			if d := math.Abs(node.CumulativeCost + edges.cost(node, child) - child.CumulativeCost); d > 1e-6 {
+				t.Errorf("%s: the cost to %v is off by %f", name, child.Coord, d)
+			}
+			walk(child)
+		}
+	}
+	walk(root)
+	return count
+}
+
+func checkPathIsFree(t *testing.T, name string, path []*geom.Coord, obstacleMap ObstacleMap) {
+	for i := 1; i < len(path); i++ {
+		if !obstacleMap.SegmentIsFree(*path[i-1], *path[i]) {
+			t.Errorf("%s: the path is blocked from %v to %v", name, *path[i-1], *path[i])
+		}
+	}
+}
+
+func dynamicTestConfig() PlannerConfig {
+	config := DefaultPlannerConfig(200, 200)
+	config.StartPoint = &geom.Coord{X: 20, Y: 100}
+	config.EndPoint = &geom.Coord{X: 180, Y: 100}
+	config.CostFunction = EuclideanCost{}
+	config.NodeDensity = 0.03
+	return config
+}
+
+func TestTreeRepairAfterAddingAndRemovingObstacles(t *testing.T) {
+	wall := &geom.Rect{Min: geom.Coord{X: 95, Y: 40}, Max: geom.Coord{X: 105, Y: 190}}
+	for _, costName := range []string{"euclidean", "unseen area"} {
+		config := dynamicTestConfig()
+		if costName == "unseen area" {
+			config.CostFunction = nil
+		}
+		rrt, err := NewRrtStar(NewVectorObstacleMap(200, 200, nil, nil, nil), nil, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2000; i++ {
+			rrt.Sample()
+		}
+
+		if err := rrt.AddObstacle(wall); err != nil {
+			t.Fatal(err)
+		}
+		if count := checkTree(t, costName+" add", &rrt.PlannerBase, rrt.Root, rrt.straightEdges()); count != rrt.rtree.Size() {
+			t.Errorf("%s add: %d nodes in the tree but %d in the rtree", costName, count, rrt.rtree.Size())
+		}
+		checkPathIsFree(t, costName+" add", rrt.BestPath, rrt.obstacleMap)
+
+		if err := rrt.RemoveObstacle(&geom.Rect{Min: wall.Min, Max: wall.Max}); err != nil {
+			t.Fatal(err)
+		}
+		if count := checkTree(t, costName+" remove", &rrt.PlannerBase, rrt.Root, rrt.straightEdges()); count != rrt.rtree.Size() {
+			t.Errorf("%s remove: %d nodes in the tree but %d in the rtree", costName, count, rrt.rtree.Size())
+		}
+		if err := rrt.RemoveObstacle(wall); err == nil {
+			t.Errorf("%s: removing an obstacle twice didn't fail", costName)
+		}
+		if err := rrt.AddObstacle(&geom.Rect{Min: geom.Coord{X: 10, Y: 90}, Max: geom.Coord{X: 30, Y: 110}}); err == nil {
+			t.Errorf("%s: covering the start point didn't fail", costName)
+		}
+	}
+}
+
+func TestTreeRepairOfEveryPlanner(t *testing.T) {
+	wall := &geom.Rect{Min: geom.Coord{X: 95, Y: 40}, Max: geom.Coord{X: 105, Y: 190}}
+	emptyMap := func() ObstacleMap { return NewVectorObstacleMap(200, 200, nil, nil, nil) }
+
+	bi, _ := NewBiRrtStar(emptyMap(), nil, dynamicTestConfig())
+	for i := 0; i < 2000; i++ {
+		bi.Sample()
+	}
+	if err := bi.AddObstacle(wall); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, "bi start tree", &bi.PlannerBase, bi.Root, bi.edgesIn(bi.rtree))
+	checkTree(t, "bi goal tree", &bi.PlannerBase, bi.GoalRoot, bi.edgesIn(bi.goalRtree))
+	checkPathIsFree(t, "bi", bi.BestPath, bi.obstacleMap)
+
+	config := dynamicTestConfig()
+	config.TurningRadius = 5
+	dubins, _ := NewDubinsRrtStar(emptyMap(), nil, config)
+	for i := 0; i < 800; i++ {
+		dubins.Sample()
+	}
+	if err := dubins.AddObstacle(wall); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, "dubins add", &dubins.PlannerBase, dubins.Root, dubins.curveEdges())
+	if err := dubins.RemoveObstacle(wall); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, "dubins remove", &dubins.PlannerBase, dubins.Root, dubins.curveEdges())
+
+	fmtStar, _ := NewFmtStar(emptyMap(), nil, dynamicTestConfig())
+	for i := 0; i < 1000; i++ {
+		fmtStar.Sample()
+	}
+	if err := fmtStar.AddObstacle(wall); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, "fmt", &fmtStar.PlannerBase, fmtStar.Root, fmtStar.straightEdges())
+	checkPathIsFree(t, "fmt", fmtStar.BestPath, fmtStar.obstacleMap)
+}
+
+func TestRasterObstaclePaintingAndClearing(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	// an obstacle that came with the image, under where one will be painted and cleared
+	for y := 40; y < 50; y++ {
+		for x := 40; x < 50; x++ {
+			img.Pix[img.PixOffset(x, y)] = 255
+		}
+	}
+	original := NewRasterObstacleMap(img).Inflate(2)
+
+	rect := &geom.Rect{Min: geom.Coord{X: 30, Y: 30}, Max: geom.Coord{X: 60, Y: 45}}
+	painted, err := original.WithObstacle(rect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, point := range []geom.Coord{{X: 31, Y: 31}, {X: 59, Y: 44}, {X: 28.5, Y: 40}} {
+		if !painted.PointIntersects(point) {
+			t.Errorf("%v isn't blocked after painting", point)
+		}
+	}
+	if painted.PointIntersects(geom.Coord{X: 25, Y: 40}) {
+		t.Error("painting reached further than the inflation")
+	}
+	if original.PointIntersects(geom.Coord{X: 31, Y: 31}) {
+		t.Error("painting changed the original map")
+	}
+
+	cleared, err := painted.WithoutObstacle(&geom.Rect{Min: rect.Min, Max: rect.Max})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range cleared.Image.Pix {
+		if cleared.Image.Pix[i] != original.Image.Pix[i] {
+			t.Fatalf("pixel %d is %d after clearing, it was %d", i, cleared.Image.Pix[i], original.Image.Pix[i])
+		}
+	}
+	if _, err := cleared.WithoutObstacle(rect); err == nil {
+		t.Error("clearing an obstacle that isn't painted didn't fail")
+	}
+}
+
+func TestUnseenAreaCostUpdatesItsFieldWhereTheChangeCanBeSeen(t *testing.T) {
+	const width, height, spacing = 200, 150, 5.0
+	rects := []*geom.Rect{
+		{Min: geom.Coord{X: 20, Y: 20}, Max: geom.Coord{X: 60, Y: 40}},
+		{Min: geom.Coord{X: 120, Y: 90}, Max: geom.Coord{X: 140, Y: 140}}}
+	circles := []*Circle{{Coord: geom.Coord{X: 160, Y: 40}, Radius: 12}}
+	field, err := NewVisibilityField(width, height, rects, nil, circles, viewshed.Sensor{}, spacing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost := NewUnseenAreaCost(width, height, rects, nil, circles)
+	cost.Field = field
+
+	added := append(append([]*geom.Rect(nil), rects...), &geom.Rect{Min: geom.Coord{X: 80, Y: 60}, Max: geom.Coord{X: 100, Y: 100}})
+	for _, step := range []struct {
+		name  string
+		rects []*geom.Rect
+	}{{"added", added}, {"removed", rects[1:]}} {
+		if err := cost.LoadMap(width, height, step.rects, nil, circles); err != nil {
+			t.Fatal(err)
+		}
+		if cost.Field == field {
+			t.Fatalf("%s: the field wasn't replaced", step.name)
+		}
+		want, _ := NewVisibilityField(width, height, step.rects, nil, circles, viewshed.Sensor{}, spacing)
+		if cost.Field.Hash != want.Hash {
+			t.Errorf("%s: the updated field's hash doesn't match the map", step.name)
+		}
+		for i := range want.Unseen {
+			if d := math.Abs(cost.Field.Unseen[i] - want.Unseen[i]); d > 0.01 {
+				t.Errorf("%s: grid point %d is off by %f", step.name, i, d)
+			}
+		}
+	}
+}
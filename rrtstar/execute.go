@@ -0,0 +1,254 @@
+package rrtstar
+
+import (
+	"time"
+
+	"github.com/dhconnelly/rtreego"
+	"github.com/skelterjohn/geom"
+)
+
+// Executor is a planner that can drive the vehicle along its best path in real time, RT-RRT* style.
+// The tree is rerooted at each node the vehicle reaches instead of growing a new root under it
+type Executor interface {
+	Planner
+	Execute(elapsed time.Duration)
+}
+
+// vehicle is how far a planner executing its best path has got
+type vehicle struct {
+	speed  float64
+	budget time.Duration
+	reach  float64
+
+	// target is the node the vehicle is driving toward from the root, and travelled is how far along
+	// the edge to it the vehicle has got
+	target    *Node
+	travelled float64
+
+	// rewireQueue spreads out from the root after each reroot so the rest of the tree catches up with it
+	rewireQueue  []*Node
+	rewireQueued map[*Node]bool
+}
+
+// nextOnPath returns the node after the root on the way to the end, or nil if there isn't one
+func (p *PlannerBase) nextOnPath() *Node {
+	if p.endNode == nil {
+		return nil
+	}
+	for node := p.endNode; node.parent != nil; node = node.parent {
+		if node.parent == p.Root {
+			return node
+		}
+	}
+	return nil
+}
+
+// rerootAt makes node the root of the tree in rtree by turning round the edges between it and the
+// old root. If one of them can't be turned round, the old root's side of the tree is joined back on
+// wherever it can be, and anything that can't be stays hanging from the old root
+func (p *PlannerBase) rerootAt(rtree *rtreego.Rtree, node *Node, edges treeEdges) {
+	var ancestors []*Node
+	for ancestor := node.parent; ancestor != nil; ancestor = ancestor.parent {
+		ancestors = append(ancestors, ancestor)
+	}
+
+	node.parent.RemoveChild(node)
+	node.parent = nil
+	node.updateCumulativeCost(0)
+	p.Root = node
+
+	previous := node
+	for _, ancestor := range ancestors {
+		if !edges.isFree(previous, ancestor) {
+			// everything that's left still hangs from the old root, and whatever can't be joined on
+			// anywhere else stays there
+			oldRoot := ancestors[len(ancestors)-1]
+			if len(p.rejoinOrphans(rtree, node, []*Node{oldRoot}, edges)) > 0 {
+				p.hangUnchecked(node, oldRoot, edges)
+			}
+			return
+		}
+		edges.attach(previous, ancestor, edges.cost(previous, ancestor))
+		previous = ancestor
+	}
+}
+
+// hangUnchecked puts child under parent without checking the edge between them. The vehicle was just
+// at child, so the way back to it is clear, but it's remembered so a new obstacle can cut it
+func (p *PlannerBase) hangUnchecked(parent, child *Node, edges treeEdges) {
+	edges.attach(parent, child, edges.cost(parent, child))
+	p.uncheckedHop = child
+}
+
+// moveRoot takes the root of the tree to point without adding a node. The tree is rerooted at the
+// node nearest point first, so the root only moves as far as it has to, and then that node is moved.
+// It returns the nodes that can't be reached from point any more, which have been removed
+func (p *PlannerBase) moveRoot(point geom.Coord, edges treeEdges) []*Node {
+	p.rerootNear(point, edges)
+	return p.relocateRoot(p.rtree, p.Root, point, edges)
+}
+
+// rerootNear reroots the tree at the node nearest point, if that isn't the root already
+func (p *PlannerBase) rerootNear(point geom.Coord, edges treeEdges) {
+	nearest := p.rtree.NearestNeighbor(rtreego.Point{point.X, point.Y}).(*Node)
+	// the goal node and samples fmt* hasn't reached yet can't be driven to
+	if nearest != p.Root && nearest != p.endNode && nearest.parent != nil {
+		p.rerootAt(p.rtree, nearest, edges)
+		p.vehicle.target = nil
+	}
+}
+
+// relocateRoot moves root to point in place and joins its children to it again from there. Children
+// it can't reach any more are joined on wherever they can be, and the ones that can't are removed and
+// returned
+func (p *PlannerBase) relocateRoot(rtree *rtreego.Rtree, root *Node, point geom.Coord, edges treeEdges) []*Node {
+	rtree.Delete(root)
+	root.Coord = point
+	rtree.Insert(root)
+	root.UnseenArea = p.getPointCost(&root.Coord)
+
+	var orphanRoots []*Node
+	// children are moved as they're joined again, so go through a copy
+	for _, child := range append([]*Node(nil), root.Children...) {
+		if edges.isFree(root, child) {
+			edges.attach(root, child, edges.cost(root, child))
+		} else {
+			root.RemoveChild(child)
+			child.parent = nil
+			orphanRoots = append(orphanRoots, child)
+		}
+	}
+	return p.reconnectOrphans(rtree, root, orphanRoots, edges)
+}
+
+// pruneOutOfRange removes the branches of the tree that never come within reach of center, keeping
+// the way to the end. It returns the nodes it removed
+func (p *PlannerBase) pruneOutOfRange(rtree *rtreego.Rtree, center geom.Coord, reach float64) []*Node {
+	var removed []*Node
+	var keep func(node *Node) bool
+	keep = func(node *Node) bool {
+		kept := node == p.Root || node == p.endNode || euclideanDistance(&node.Coord, &center) <= reach
+		// children are removed as they're found, so go through a copy
+		for _, child := range append([]*Node(nil), node.Children...) {
+			if keep(child) {
+				kept = true
+			} else {
+				removed = append(removed, p.removeNode(rtree, child))
+			}
+		}
+		return kept
+	}
+	keep(p.Root)
+	return removed
+}
+
+// advance drives the vehicle distance along the best path, rerooting the tree at each node it reaches
+// and pruning the branches it leaves out of range. It reports whether the root moved
+func (p *PlannerBase) advance(distance float64, edges treeEdges) bool {
+	moved := false
+	for {
+		if p.vehicle.target == nil {
+			if p.vehicle.target = p.nextOnPath(); p.vehicle.target == nil {
+				break
+			}
+			p.vehicle.travelled = 0
+		}
+
+		point, length := edges.travel(p.Root, p.vehicle.target, p.vehicle.travelled+distance)
+		if p.vehicle.travelled+distance < length {
+			p.vehicle.travelled += distance
+			*p.StartPoint = point
+			break
+		}
+
+		distance -= length - p.vehicle.travelled
+		p.rerootAt(p.rtree, p.vehicle.target, edges)
+		// the way on may still go back through where the vehicle came from until its neighbors
+		// are put under the new root, and it would turn round to follow it
+		p.rewireNeighbors(p.rtree, p.Root, p.Root, edges)
+		p.vehicle.target = nil
+		*p.StartPoint = p.Root.Coord
+		moved = true
+	}
+
+	if moved {
+		if p.vehicle.reach > 0 {
+			p.forgetRemoved(p.pruneOutOfRange(p.rtree, p.Root.Coord, p.vehicle.reach))
+		}
+		p.vehicle.rewireQueue = append(p.vehicle.rewireQueue[:0], p.Root)
+		p.vehicle.rewireQueued = map[*Node]bool{p.Root: true}
+	}
+	return moved
+}
+
+// rewireFromRoot rewires around the next node in the queue spreading out from the root, so the tree
+// catches up with a new root a little at a time
+func (p *PlannerBase) rewireFromRoot(edges treeEdges) {
+	if len(p.vehicle.rewireQueue) == 0 {
+		return
+	}
+	node := p.vehicle.rewireQueue[0]
+	p.vehicle.rewireQueue = p.vehicle.rewireQueue[1:]
+
+	// it may have been pruned since it was queued
+	if node != p.Root && node.parent == nil {
+		return
+	}
+
+	for _, neighbor := range p.rewireNeighbors(p.rtree, p.Root, node, edges) {
+		if !p.vehicle.rewireQueued[neighbor] {
+			p.vehicle.rewireQueued[neighbor] = true
+			p.vehicle.rewireQueue = append(p.vehicle.rewireQueue, neighbor)
+		}
+	}
+}
+
+// sampleFor samples until the tick's budget runs out, rewiring out from the root in between
+func (p *PlannerBase) sampleFor(sample func(), edges treeEdges) {
+	deadline := time.Now().Add(p.vehicle.budget)
+	for time.Now().Before(deadline) {
+		sample()
+		p.rewireFromRoot(edges)
+	}
+}
+
+// Execute drives the vehicle along the best path for elapsed at the execution speed, then samples
+// for the rest of the tick's budget
+func (r *RrtStar) Execute(elapsed time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	edges := r.straightEdges()
+	r.advance(r.vehicle.speed*elapsed.Seconds(), edges)
+	r.sampleFor(r.sample, edges)
+	r.refreshBestPath()
+}
+
+// Execute drives the vehicle along the best path for elapsed at the execution speed, then samples
+// for the rest of the tick's budget
+func (d *DubinsRrtStar) Execute(elapsed time.Duration) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	edges := d.curveEdges()
+	d.advance(d.vehicle.speed*elapsed.Seconds(), edges)
+	d.sampleFor(d.sample, edges)
+	d.refreshBestPath()
+}
+
+// Execute drives the vehicle along the best path for elapsed at the execution speed, then samples
+// for the rest of the tick's budget. What's been seen is counted from the root, so each reroot works
+// out the whole tree's coverage again
+func (c *CoverageRrtStar) Execute(elapsed time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	edges := c.coverageEdges()
+	if c.advance(c.vehicle.speed*elapsed.Seconds(), edges) {
+		// the new root was reached through everything seen so far, so it still carries all of it
+		c.collected = c.Root.seen.union(nil, c.collected)
+		c.attach(c.Root, c.Root.view)
+	}
+	c.sampleFor(c.sample, edges)
+	c.refreshBestPath()
+}
@@ -0,0 +1,114 @@
+package rrtstar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dhconnelly/rtreego"
+	"github.com/skelterjohn/geom"
+)
+
+func TestMoveStartPointAddsNoNodes(t *testing.T) {
+	rects := []*geom.Rect{{Min: geom.Coord{X: 95, Y: 40}, Max: geom.Coord{X: 105, Y: 190}}}
+	obstacles := func() ObstacleMap { return NewVectorObstacleMap(200, 200, rects, nil, nil) }
+
+	// the planners move the start they're given, so each gets its own
+	rrt, _ := NewRrtStar(obstacles(), rects, dynamicTestConfig())
+	bi, _ := NewBiRrtStar(obstacles(), rects, dynamicTestConfig())
+	fmtStar, _ := NewFmtStar(obstacles(), rects, dynamicTestConfig())
+	dubinsConfig := dynamicTestConfig()
+	dubinsConfig.TurningRadius = 5
+	dubins, _ := NewDubinsRrtStar(obstacles(), rects, dubinsConfig)
+	coverageConfig := dynamicTestConfig()
+	coverageConfig.CoverageCellSize = 5
+	coverage, _ := NewCoverageRrtStar(obstacles(), rects, coverageConfig)
+
+	for _, test := range []struct {
+		name    string
+		planner Planner
+		base    *PlannerBase
+		edges   treeEdges
+	}{
+		{"rrt*", rrt, &rrt.PlannerBase, rrt.straightEdges()},
+		{"bi", bi, &bi.PlannerBase, bi.edgesIn(bi.rtree)},
+		{"fmt*", fmtStar, &fmtStar.PlannerBase, fmtStar.straightEdges()},
+		{"dubins", dubins, &dubins.PlannerBase, dubins.curveEdges()},
+		{"coverage", coverage, &coverage.PlannerBase, coverage.coverageEdges()},
+	} {
+		for i := 0; i < 600; i++ {
+			test.planner.Sample()
+		}
+		nodes, size := test.base.NumNodes, test.base.rtree.Size()
+
+		for move := 0; move < 20; move++ {
+			test.planner.MoveStartPoint(2, 1)
+			if test.base.NumNodes > nodes || test.base.rtree.Size() > size {
+				t.Fatalf("%s: moving the start %d times went from %d nodes to %d", test.name, move+1, nodes, test.base.NumNodes)
+			}
+			if test.base.Root.Coord != *test.base.StartPoint {
+				t.Fatalf("%s: the root is at %v but the start is at %v", test.name, test.base.Root.Coord, *test.base.StartPoint)
+			}
+		}
+		checkTree(t, test.name, test.base, test.base.Root, test.edges)
+		checkPathIsFree(t, test.name, test.planner.GetBestPath(), test.base.obstacleMap)
+	}
+}
+
+func TestExecuteKeepsTheTreeWhole(t *testing.T) {
+	rects := []*geom.Rect{{Min: geom.Coord{X: 95, Y: 40}, Max: geom.Coord{X: 105, Y: 190}}}
+	config := dynamicTestConfig()
+	config.ExecutionSpeed = 40
+	config.ExecutionBudget = 2 * time.Millisecond
+	config.ExecutionRange = 80
+	rrt, err := NewRrtStar(NewVectorObstacleMap(200, 200, rects, nil, nil), rects, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1500; i++ {
+		rrt.Sample()
+	}
+
+	for tick := 0; tick < 200 && rrt.Root != rrt.endNode; tick++ {
+		rrt.Execute(100 * time.Millisecond)
+		if count := checkTree(t, "rrt*", &rrt.PlannerBase, rrt.Root, rrt.straightEdges()); uint64(count) != rrt.NumNodes || count != rrt.rtree.Size() {
+			t.Fatalf("tick %d: %d nodes in the tree, %d counted and %d in the rtree", tick, count, rrt.NumNodes, rrt.rtree.Size())
+		}
+	}
+	if rrt.Root != rrt.endNode {
+		t.Errorf("the vehicle only got to %v", *rrt.StartPoint)
+	}
+}
+
+func TestNewObstacleCutsAnUncheckedHop(t *testing.T) {
+	config := DefaultPlannerConfig(800, 100)
+	config.StartPoint = &geom.Coord{X: 10, Y: 50}
+	config.EndPoint = &geom.Coord{X: 790, Y: 50}
+	config.CostFunction = EuclideanCost{}
+	rrt, err := NewRrtStar(NewVectorObstacleMap(800, 100, nil, nil, nil), nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3000; i++ {
+		rrt.Sample()
+	}
+
+	// a hop much longer than any edge, with its middle too far from either end for them to be near it
+	var far *Node
+	for _, spatial := range rrt.rtree.SearchIntersect(rtreego.Point{700, 50}.ToRect(50)) {
+		if node := spatial.(*Node); node != rrt.endNode {
+			far = node
+			break
+		}
+	}
+	if far == nil {
+		t.Fatal("the tree didn't grow across the map")
+	}
+	edges := rrt.straightEdges()
+	rrt.hangUnchecked(rrt.Root, far, edges)
+
+	middle := geom.Coord{X: (rrt.Root.X + far.X) / 2, Y: (rrt.Root.Y + far.Y) / 2}
+	if err := rrt.AddObstacle(&geom.Rect{Min: geom.Coord{X: middle.X - 2, Y: middle.Y - 2}, Max: geom.Coord{X: middle.X + 2, Y: middle.Y + 2}}); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, "rrt*", &rrt.PlannerBase, rrt.Root, edges)
+}
@@ -1,10 +1,8 @@
 package rrtstar
 
 import (
-	"image"
 	"math"
 
-	"github.com/brychanrobot/go-halton"
 	"github.com/dhconnelly/rtreego"
 	"github.com/skelterjohn/geom"
 )
@@ -12,84 +10,42 @@ import (
 // FmtStar holds all of the information for an rrt*
 type FmtStar struct {
 	PlannerBase
-	rtreeOpen    *rtreego.Rtree
-	open         []*Node
-	obstacleArea float64
+	rtreeOpen *rtreego.Rtree
+	open      []*Node
 }
 
 // NewFmtStar creates a new rrt Star
-func NewFmtStar(obstacleImage *image.Gray, obstacleRects []*geom.Rect, maxSegment float64, width, height int,
-	startPoint, endPoint *geom.Coord) *FmtStar {
-
-	nodeThreshold := uint64(0.015 * float64(width*height))
-
-	if startPoint == nil {
-		startPoint = randomOpenAreaPoint(obstacleImage, width, height)
+func NewFmtStar(obstacleMap ObstacleMap, obstacleRects []*geom.Rect, config PlannerConfig) (*FmtStar, error) {
+	base, err := newPlannerBase(obstacleMap, obstacleRects, config)
+	if err != nil {
+		return nil, err
 	}
-	//var endPoint *geom.Coord
-	//make sure the endpoint is at least half the screen away from the start to guarantee some difficulty
-	if endPoint == nil {
-		for endPoint == nil || euclideanDistance(startPoint, endPoint) < float64(width)/2.0 {
-			endPoint = randomOpenAreaPoint(obstacleImage, width, height)
-		}
-	}
-
-	fmtRoot := &Node{parent: nil, Coord: *startPoint, CumulativeCost: 0, Status: Open}
-	rtree := rtreego.NewTree(2, 25, 50)
-	rtree.Insert(fmtRoot)
 
-	rtreeOpen := rtreego.NewTree(2, 25, 50)
-	rtreeOpen.Insert(fmtRoot)
+	fmtStar := &FmtStar{PlannerBase: base, rtreeOpen: config.newRtree()}
+	fmtStar.nodeThreshold = uint64(config.FmtSampleDensity * float64(config.Width*config.Height))
 
-	endNode := &Node{parent: nil, Coord: *endPoint, CumulativeCost: 0}
-	rtree.Insert(endNode)
+	fmtStar.Root = &Node{parent: nil, Coord: *fmtStar.StartPoint, CumulativeCost: 0, Status: Open}
+	fmtStar.rtree.Insert(fmtStar.Root)
+	fmtStar.rtreeOpen.Insert(fmtStar.Root)
+	fmtStar.NumNodes = 1
 
-	obstacleArea := 0.0
-	for _, obstacle := range obstacleRects {
-		obstacleArea += obstacle.Width() * obstacle.Height()
-	}
-
-	fmtStar := &FmtStar{
-		PlannerBase: PlannerBase{
-			obstacleImage:      obstacleImage,
-			obstacleRects:      obstacleRects,
-			rtree:              rtree,
-			Root:               fmtRoot,
-			maxSegment:         maxSegment,
-			rewireNeighborhood: maxSegment * 6,
-			width:              width,
-			height:             height,
-			StartPoint:         startPoint,
-			EndPoint:           endPoint,
-			endNode:            endNode,
-			mapArea:            float64(width * height),
-			nodeThreshold:      nodeThreshold,
-			NumNodes:           1,
-			haltonX:            halton.NewHaltonSampler(19),
-			haltonY:            halton.NewHaltonSampler(23),
-			unseenAreaMap:      make(map[geom.Coord]float64),
-			obstacleArea:       obstacleArea},
-
-		rtreeOpen: rtreeOpen}
-
-	fmtStar.Viewshed.LoadMap(float64(width), float64(height), 0, obstacleRects, nil)
-	//rrtStaf.Viewshed.UpdateCenterLocation(float64(startPoint.X), float64(startPoint.Y))
-	//rrtStaf.Viewshed.Sweep()
+	fmtStar.endNode = &Node{parent: nil, Coord: *fmtStar.EndPoint, CumulativeCost: 0}
+	fmtStar.rtree.Insert(fmtStar.endNode)
 
 	//rrtStaf.renderCostMap()
 	//fmtStar.Root.UnseenArea = fmtStar.getUnseenArea(startPoint)
 
-	for n := uint64(0); n < nodeThreshold; n++ {
-		point := fmtStar.nextHaltonPoint(width, height)
-		if fmtStar.obstacleImage.GrayAt(int(point.X), int(point.Y)).Y < 50 {
+	for n := uint64(0); n < fmtStar.nodeThreshold; n++ {
+		point := fmtStar.nextHaltonPoint(fmtStar.width, fmtStar.height)
+		if !fmtStar.obstacleMap.PointIntersects(point) {
 			node := &Node{parent: nil, Coord: point, CumulativeCost: math.MaxFloat64}
-			rtree.Insert(node)
+			fmtStar.rtree.Insert(node)
 		}
 	}
 
 	fmtStar.open = append(fmtStar.open, fmtStar.Root)
 
-	return fmtStar
+	return fmtStar, nil
 }
 
 func (f *FmtStar) getBestOpenNeighbor(point *geom.Coord, neighborhoodSize float64) (*Node, float64, []*Node, []float64) {
@@ -116,9 +72,58 @@ func (f *FmtStar) getBestOpenNeighbor(point *geom.Coord, neighborhoodSize float6
 }
 
 func (f *FmtStar) refreshBestPath() {
+	// fmt* reaches the goal itself, a moved goal has to be joined back on
+	if f.endNode == nil || f.endNode.Status == Closed && !f.isGoalReachable() {
+		f.joinGoal(f.rtree, f.straightEdges(), f.rewireNeighborhood)
+	}
 	f.traceBestPath()
 }
 
+// moveStartPoint takes the root to the moved start like the other planners. An open root is in the
+// open rtree by where it was, so it's taken out while it moves
+func (f *FmtStar) moveStartPoint(dx, dy float64) {
+	if dx != 0 || dy != 0 {
+		f.StartPoint.X += dx
+		f.StartPoint.Y += dy
+
+		edges := f.straightEdges()
+		f.rerootNear(*f.StartPoint, edges)
+		open := f.Root.Status == Open
+		if open {
+			f.rtreeOpen.Delete(f.Root)
+		}
+		removed := f.relocateRoot(f.rtree, f.Root, *f.StartPoint, edges)
+		if open {
+			f.rtreeOpen.Insert(f.Root)
+		}
+		f.forgetRemoved(removed)
+		f.rewireNeighbors(f.rtree, f.Root, f.Root, edges)
+		f.refreshBestPath()
+	}
+}
+
+// moveEndPoint moves the goal node like the other planners, but fmt* doesn't grow toward it since
+// its samples already cover the map
+func (f *FmtStar) moveEndPoint(dx, dy float64) {
+	if dx != 0 || dy != 0 {
+		// the goal is about to be joined on as a leaf, so it can't stay open for expanding
+		if f.endNode != nil && f.endNode.Status == Open {
+			for i, node := range f.open {
+				if node == f.endNode {
+					f.open = append(f.open[:i], f.open[i+1:]...)
+					break
+				}
+			}
+			f.rtreeOpen.Delete(f.endNode)
+		}
+
+		f.EndPoint.X += dx
+		f.EndPoint.Y += dy
+		f.trackGoal(f.rtree, f.straightEdges(), nil)
+		f.endNode.Status = Closed
+	}
+}
+
 func (f *FmtStar) popBestOpenNode() *Node {
 	var bestNode *Node
 	bestCost := math.MaxFloat64
@@ -142,10 +147,10 @@ func (f *FmtStar) sampleFmtStar() {
 		neighbor := spatialNeighbor.(*Node)
 
 		if neighbor.Status == Unvisited {
-			unseenArea := (f.mapArea - f.getViewArea(&neighbor.Coord)) / f.mapArea
+			unseenArea := f.getPointCost(&neighbor.Coord)
 			bestParent, bestCost, _, _ := f.getBestOpenNeighbor(&neighbor.Coord, f.rewireNeighborhood)
 
-			if bestParent != nil && !f.lineIntersectsObstacle(neighbor.Coord, bestParent.Coord, 200) {
+			if bestParent != nil && !f.lineIntersectsObstacle(neighbor.Coord, bestParent.Coord) {
 				bestParent.AddChild(neighbor, bestCost, unseenArea)
 				neighbor.Status = Open
 				f.open = append(f.open, neighbor)
@@ -164,7 +169,7 @@ func (f *FmtStar) sampleFmtStarWithRewire() {
 	point := f.nextHaltonPoint(f.width, f.height)
 	bestNeighbor, _, neighbors, _ := f.getBestNeighbor(&point, float64(f.rewireNeighborhood*1.5))
 	for _, neighbor := range neighbors {
-		if bestNeighbor != nil && neighbor != bestNeighbor && !f.lineIntersectsObstacle(bestNeighbor.Coord, neighbor.Coord, 200) {
+		if bestNeighbor != nil && neighbor != bestNeighbor && !f.lineIntersectsObstacle(bestNeighbor.Coord, neighbor.Coord) {
 			cost := f.getCost(&bestNeighbor.Coord, &neighbor.Coord)
 			if cost+bestNeighbor.CumulativeCost < neighbor.CumulativeCost {
 				neighbor.Rewire(bestNeighbor, cost)
@@ -173,8 +178,7 @@ func (f *FmtStar) sampleFmtStarWithRewire() {
 	}
 }
 
-// SampleFmtStar performs one iteration of rrt*
-func (f *FmtStar) Sample() {
+func (f *FmtStar) sample() {
 	f.IsAddingNodes = len(f.open) != 0 //f.NumNodes < r.nodeThreshold
 	if f.IsAddingNodes {
 		f.sampleFmtStar()
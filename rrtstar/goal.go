@@ -0,0 +1,100 @@
+package rrtstar
+
+import (
+	"math"
+
+	"github.com/dhconnelly/rtreego"
+	"github.com/skelterjohn/geom"
+)
+
+// isGoalReachable is false while a moved goal has nothing in reach that can get to it
+func (p *PlannerBase) isGoalReachable() bool {
+	return p.endNode != nil && (p.endNode == p.Root || p.endNode.parent != nil)
+}
+
+// isGoalReachable is true once the trees have met
+func (b *BiRrtStar) isGoalReachable() bool {
+	return b.bestBridge != nil
+}
+
+// detachGoal takes the goal node out of the tree and rtree so it can move. Anything that grew from it
+// is joined back on elsewhere
+func (p *PlannerBase) detachGoal(rtree *rtreego.Rtree, edges treeEdges) {
+	goal := p.endNode
+	if p.vehicle.target == goal {
+		p.vehicle.target = nil
+	}
+
+	// fmt* puts the goal in as a sample before anything reaches it
+	if goal.parent == nil {
+		rtree.Delete(goal)
+		return
+	}
+
+	children := goal.Children
+	for _, child := range children {
+		child.parent = nil
+	}
+	goal.Children = nil
+	p.removeNode(rtree, goal)
+	p.forgetRemoved(p.reconnectOrphans(rtree, p.Root, children, edges))
+}
+
+// joinGoal moves the goal node to the end point and joins it to the neighbor within reach that gets
+// to it most cheaply, putting it back in rtree. A goal node is made if there isn't one yet. It
+// reports whether any neighbor could get to it
+func (p *PlannerBase) joinGoal(rtree *rtreego.Rtree, edges treeEdges, reach float64) bool {
+	if p.endNode == nil {
+		p.endNode = &Node{Coord: *p.EndPoint}
+	}
+	goal := p.endNode
+	goal.Coord = *p.EndPoint
+
+	var bestParent *Node
+	bestCost := 0.0
+	bestCumulativeCost := math.Inf(1)
+	rtreePoint := rtreego.Point{goal.X, goal.Y}
+	for _, spatialNeighbor := range rtree.SearchIntersect(rtreePoint.ToRect(reach)) {
+		neighbor := spatialNeighbor.(*Node)
+		if neighbor == goal || neighbor != p.Root && neighbor.parent == nil || !edges.isFree(neighbor, goal) {
+			continue
+		}
+		if cost := edges.cost(neighbor, goal); neighbor.CumulativeCost+cost < bestCumulativeCost {
+			bestParent = neighbor
+			bestCost = cost
+			bestCumulativeCost = neighbor.CumulativeCost + cost
+		}
+	}
+
+	if bestParent == nil {
+		p.BestPath = p.BestPath[:0]
+		return false
+	}
+
+	// the goal is a leaf that's joined on directly, so fmt* mustn't reach or expand it again
+	goal.Status = Closed
+	edges.attach(bestParent, goal, bestCost)
+	rtree.Delete(goal)
+	rtree.Insert(goal)
+	p.NumNodes++
+	return true
+}
+
+// trackGoal moves the goal node to the end point and joins it back onto the tree. When nothing in
+// reach can get to it, grow is given a chance to add a node toward it, and the goal stays unreachable
+// until a later refresh can join it
+func (p *PlannerBase) trackGoal(rtree *rtreego.Rtree, edges treeEdges, grow func(rtree *rtreego.Rtree, point geom.Coord) *Node) {
+	if p.endNode == p.Root {
+		// the vehicle got to the goal, so the root stays where it is and a new goal node sets off
+		p.endNode = nil
+	}
+	if p.endNode != nil {
+		p.detachGoal(rtree, edges)
+	}
+
+	if !p.joinGoal(rtree, edges, p.rewireNeighborhood) && grow != nil {
+		if grow(rtree, *p.EndPoint) != nil {
+			p.joinGoal(rtree, edges, p.rewireNeighborhood)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package rrtstar
+
+import (
+	"testing"
+
+	"github.com/skelterjohn/geom"
+)
+
+func TestMovingTheGoalAddsNoNodes(t *testing.T) {
+	rects := []*geom.Rect{{Min: geom.Coord{X: 95, Y: 40}, Max: geom.Coord{X: 105, Y: 190}}}
+	obstacles := func() ObstacleMap { return NewVectorObstacleMap(200, 200, rects, nil, nil) }
+
+	rrt, _ := NewRrtStar(obstacles(), rects, dynamicTestConfig())
+	bi, _ := NewBiRrtStar(obstacles(), rects, dynamicTestConfig())
+	dubinsConfig := dynamicTestConfig()
+	dubinsConfig.TurningRadius = 5
+	dubins, _ := NewDubinsRrtStar(obstacles(), rects, dubinsConfig)
+	coverageConfig := dynamicTestConfig()
+	coverageConfig.CoverageCellSize = 5
+	coverage, _ := NewCoverageRrtStar(obstacles(), rects, coverageConfig)
+
+	for _, test := range []struct {
+		name    string
+		planner Planner
+		base    *PlannerBase
+		// goal is the node that follows the end point, in the tree under root
+		goal, root func() *Node
+		edges      treeEdges
+	}{
+		{"rrt*", rrt, &rrt.PlannerBase, func() *Node { return rrt.endNode }, func() *Node { return rrt.Root }, rrt.straightEdges()},
+		{"bi", bi, &bi.PlannerBase, func() *Node { return bi.GoalRoot }, func() *Node { return bi.GoalRoot }, bi.edgesIn(bi.goalRtree)},
+		{"dubins", dubins, &dubins.PlannerBase, func() *Node { return dubins.endNode }, func() *Node { return dubins.Root }, dubins.curveEdges()},
+		{"coverage", coverage, &coverage.PlannerBase, func() *Node { return coverage.endNode }, func() *Node { return coverage.Root }, coverage.coverageEdges()},
+	} {
+		for i := 0; i < 1500; i++ {
+			test.planner.Sample()
+		}
+		if !test.planner.GetIsGoalReachable() {
+			t.Fatalf("%s: no path to the goal", test.name)
+		}
+		nodes := test.base.NumNodes
+
+		for move := 0; move < 20; move++ {
+			test.planner.MoveEndPoint(-1, 1)
+			if test.base.NumNodes > nodes {
+				t.Fatalf("%s: moving the goal %d times went from %d nodes to %d", test.name, move+1, nodes, test.base.NumNodes)
+			}
+			if goal := test.goal(); goal == nil || goal.Coord != *test.base.EndPoint {
+				t.Fatalf("%s: the goal node didn't follow the end point to %v", test.name, *test.base.EndPoint)
+			}
+		}
+
+		checkTree(t, test.name, test.base, test.root(), test.edges)
+		checkPathIsFree(t, test.name, test.planner.GetBestPath(), test.base.obstacleMap)
+	}
+}
@@ -0,0 +1,162 @@
+package rrtstar
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/skelterjohn/geom"
+)
+
+// far stands in for infinity in the distance transform so the arithmetic doesn't produce NaNs
+const far = 1e20
+
+// InflateObstacleMap grows every obstacle by radius so a round robot can be planned for as a point.
+// The result is the configuration space of the robot, the original map is left alone
+func InflateObstacleMap(obstacleMap ObstacleMap, radius float64) (ObstacleMap, error) {
+	switch m := obstacleMap.(type) {
+	case *RasterObstacleMap:
+		return m.Inflate(radius), nil
+	case *VectorObstacleMap:
+		return m.Inflate(radius), nil
+	}
+	return nil, fmt.Errorf("can't inflate a %T", obstacleMap)
+}
+
+// distanceTransform1D computes the squared distance from each cell to the nearest zero in f
+// http://cs.brown.edu/people/pfelzens/papers/dt-final.pdf
+func distanceTransform1D(f, d []float64, v []int, z []float64) {
+	n := len(f)
+	k := 0
+	v[0] = 0
+	z[0] = -far
+	z[1] = far
+	for q := 1; q < n; q++ {
+		fq := f[q] + float64(q*q)
+		s := (fq - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		for s <= z[k] {
+			k--
+			s = (fq - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		}
+		k++
+		v[k] = q
+		z[k] = s
+		z[k+1] = far
+	}
+
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		d[q] = float64((q-v[k])*(q-v[k])) + f[v[k]]
+	}
+}
+
+// squaredDistanceToObstacles returns the squared distance from every pixel to the nearest pixel above ObstacleThreshold
+func (r *RasterObstacleMap) squaredDistanceToObstacles() []float64 {
+	bounds := r.Image.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	size := width
+	if height > size {
+		size = height
+	}
+
+	distances := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			distances[y*width+x] = far
+			if r.isPixelBlocked(bounds.Min.X+x, bounds.Min.Y+y) {
+				distances[y*width+x] = 0
+			}
+		}
+	}
+
+	f := make([]float64, size)
+	d := make([]float64, size)
+	v := make([]int, size)
+	z := make([]float64, size+1)
+
+	// columns first, then rows
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			f[y] = distances[y*width+x]
+		}
+		distanceTransform1D(f[:height], d[:height], v, z)
+		for y := 0; y < height; y++ {
+			distances[y*width+x] = d[y]
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		row := distances[y*width : (y+1)*width]
+		copy(f, row)
+		distanceTransform1D(f[:width], d[:width], v, z)
+		copy(row, d[:width])
+	}
+
+	return distances
+}
+
+// Inflate returns a map where every pixel within radius of an obstacle pixel is an obstacle
+func (r *RasterObstacleMap) Inflate(radius float64) *RasterObstacleMap {
+	bounds := r.Image.Bounds()
+	inflated := image.NewGray(bounds)
+	copy(inflated.Pix, r.Image.Pix)
+
+	radiusSquared := radius * radius
+	for i, distance := range r.squaredDistanceToObstacles() {
+		if distance <= radiusSquared {
+			x := bounds.Min.X + i%bounds.Dx()
+			y := bounds.Min.Y + i/bounds.Dx()
+			inflated.Pix[inflated.PixOffset(x, y)] = 255
+		}
+	}
+
+	return &RasterObstacleMap{
+		Image:             inflated,
+		FreeThreshold:     r.FreeThreshold,
+		ObstacleThreshold: r.ObstacleThreshold,
+		radius:            r.radius + radius}
+}
+
+// Inflate returns a map whose obstacles have grown by radius with their corners rounded off
+func (v *VectorObstacleMap) Inflate(radius float64) *VectorObstacleMap {
+	return &VectorObstacleMap{Rects: v.Rects, Polygons: v.Polygons, Circles: v.Circles, bounds: v.bounds, radius: v.radius + radius}
+}
+
+func pointToRectangleDistance(point geom.Coord, rect *geom.Rect) float64 {
+	dx := math.Max(math.Max(rect.Min.X-point.X, 0), point.X-rect.Max.X)
+	dy := math.Max(math.Max(rect.Min.Y-point.Y, 0), point.Y-rect.Max.Y)
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+func pointToSegmentDistance(point, p1, p2 geom.Coord) float64 {
+	dx := p2.X - p1.X
+	dy := p2.Y - p1.Y
+	lengthSquared := dx*dx + dy*dy
+
+	t := 0.0
+	if lengthSquared > 0 {
+		t = math.Max(0, math.Min(1, ((point.X-p1.X)*dx+(point.Y-p1.Y)*dy)/lengthSquared))
+	}
+
+	closest := geom.Coord{X: p1.X + t*dx, Y: p1.Y + t*dy}
+	return euclideanDistance(&point, &closest)
+}
+
+// segmentToRectangleDistance is zero if the segment crosses the rectangle, otherwise the closest
+// approach is between an end of the segment and the rectangle or a corner and the segment
+func segmentToRectangleDistance(p1, p2 geom.Coord, rect *geom.Rect) float64 {
+	if segmentIntersectsRectangle(p1, p2, rect) {
+		return 0
+	}
+
+	distance := math.Min(pointToRectangleDistance(p1, rect), pointToRectangleDistance(p2, rect))
+	corners := []geom.Coord{rect.Min, {X: rect.Max.X, Y: rect.Min.Y}, rect.Max, {X: rect.Min.X, Y: rect.Max.Y}}
+	for _, corner := range corners {
+		distance = math.Min(distance, pointToSegmentDistance(corner, p1, p2))
+	}
+
+	return distance
+}
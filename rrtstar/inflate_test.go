@@ -0,0 +1,104 @@
+package rrtstar
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/skelterjohn/geom"
+)
+
+func TestDistanceTransformMatchesBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	const width, height = 37, 23
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	var obstacles []image.Point
+	for i := 0; i < 12; i++ {
+		obstacle := image.Point{X: random.Intn(width), Y: random.Intn(height)}
+		img.Pix[img.PixOffset(obstacle.X, obstacle.Y)] = 255
+		obstacles = append(obstacles, obstacle)
+	}
+
+	distances := NewRasterObstacleMap(img).squaredDistanceToObstacles()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			nearest := far
+			for _, obstacle := range obstacles {
+				dx, dy := float64(x-obstacle.X), float64(y-obstacle.Y)
+				if d := dx*dx + dy*dy; d < nearest {
+					nearest = d
+				}
+			}
+			if distances[y*width+x] != nearest {
+				t.Fatalf("(%d, %d) is %f from an obstacle squared, not %f", x, y, distances[y*width+x], nearest)
+			}
+		}
+	}
+}
+
+func TestInflatedRasterCoversTheRadius(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 40, 40))
+	img.Pix[img.PixOffset(20, 20)] = 255
+	original := NewRasterObstacleMap(img)
+	inflated := original.Inflate(3)
+
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			dx, dy := x-20, y-20
+			want := dx*dx+dy*dy <= 9
+			if blocked := inflated.isPixelBlocked(x, y); blocked != want {
+				t.Errorf("(%d, %d) should be blocked: %v", x, y, want)
+			}
+		}
+	}
+	if original.isPixelBlocked(20, 23) {
+		t.Error("inflating changed the original map")
+	}
+}
+
+func TestInflatedVectorsRoundTheCorners(t *testing.T) {
+	rect := &geom.Rect{Min: geom.Coord{X: 20, Y: 20}, Max: geom.Coord{X: 40, Y: 30}}
+	original := NewVectorObstacleMap(100, 100, []*geom.Rect{rect}, nil, nil)
+	obstacleMap, err := InflateObstacleMap(original, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		point   geom.Coord
+		blocked bool
+	}{
+		{"beside an edge", geom.Coord{X: 30, Y: 34}, true},
+		{"past an edge", geom.Coord{X: 30, Y: 36}, false},
+		{"near a corner", geom.Coord{X: 43, Y: 33}, true},
+		// inside the inflated bounding box but more than the radius from the corner
+		{"off a corner", geom.Coord{X: 44.5, Y: 34.5}, false},
+	} {
+		if blocked := obstacleMap.PointIntersects(test.point); blocked != test.blocked {
+			t.Errorf("%s: %v should be blocked: %v", test.name, test.point, test.blocked)
+		}
+	}
+
+	for _, test := range []struct {
+		name   string
+		p1, p2 geom.Coord
+		free   bool
+	}{
+		{"passing within the radius", geom.Coord{X: 10, Y: 33}, geom.Coord{X: 50, Y: 33}, false},
+		{"passing outside the radius", geom.Coord{X: 10, Y: 36}, geom.Coord{X: 50, Y: 36}, true},
+		{"cutting off a rounded corner", geom.Coord{X: 40, Y: 38}, geom.Coord{X: 48, Y: 30}, true},
+		{"clipping a rounded corner", geom.Coord{X: 38, Y: 38}, geom.Coord{X: 48, Y: 28}, false},
+	} {
+		if free := obstacleMap.SegmentIsFree(test.p1, test.p2); free != test.free {
+			t.Errorf("%s: the segment from %v to %v should be free: %v", test.name, test.p1, test.p2, test.free)
+		}
+	}
+
+	if original.PointIntersects(geom.Coord{X: 30, Y: 34}) {
+		t.Error("inflating changed the original map")
+	}
+	if _, err := InflateObstacleMap(nil, 5); err == nil {
+		t.Error("inflating an unknown map didn't fail")
+	}
+}
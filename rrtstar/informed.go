@@ -0,0 +1,69 @@
+package rrtstar
+
+import (
+	"math"
+
+	"github.com/skelterjohn/geom"
+)
+
+const maxInformedAttempts = 100
+
+// informedEllipse returns the ellipse holding every point that could be on a path from start to end
+// cheaper than bestCost. ok is false if the cost function can't bound it.
+func (p *PlannerBase) informedEllipse(start, end geom.Coord, bestCost float64) (center geom.Coord, radiusX, radiusY, angle float64, ok bool) {
+	bounded, isBounded := p.costFunction.(BoundedCostFunction)
+	if !isBounded || bounded.MinCostPerDistance() <= 0 {
+		return
+	}
+
+	// the longest path that could still cost less than the best one
+	maxLength := bestCost / bounded.MinCostPerDistance()
+	minLength := euclideanDistance(&start, &end)
+	if maxLength < minLength {
+		maxLength = minLength
+	}
+
+	center = geom.Coord{X: (start.X + end.X) / 2.0, Y: (start.Y + end.Y) / 2.0}
+	radiusX = maxLength / 2.0
+	radiusY = math.Sqrt(maxLength*maxLength-minLength*minLength) / 2.0
+	angle = angleBetweenPoints(start, end)
+
+	return center, radiusX, radiusY, angle, true
+}
+
+// nextInformedPoint draws a halton point from the informed ellipse, ok is false if the ellipse
+// doesn't help, either because it can't be computed or it's bigger than the map
+func (p *PlannerBase) nextInformedPoint(start, end geom.Coord, bestCost float64) (point geom.Coord, ok bool) {
+	center, radiusX, radiusY, angle, ok := p.informedEllipse(start, end, bestCost)
+	if !ok || math.Pi*radiusX*radiusY >= p.mapArea {
+		return point, false
+	}
+
+	cos := math.Cos(angle)
+	sin := math.Sin(angle)
+	for i := 0; i < maxInformedAttempts; i++ {
+		// map the unit square onto the unit disk, then stretch and rotate it onto the ellipse
+		r := math.Sqrt(p.haltonX.Next())
+		theta := 2 * math.Pi * p.haltonY.Next()
+		x := r * math.Cos(theta) * radiusX
+		y := r * math.Sin(theta) * radiusY
+
+		point = geom.Coord{X: center.X + x*cos - y*sin, Y: center.Y + x*sin + y*cos}
+		if point.X >= 0 && point.Y >= 0 && point.X < float64(p.width) && point.Y < float64(p.height) {
+			return point, true
+		}
+	}
+
+	return point, false
+}
+
+// nextSamplePoint returns an informed sample when informed sampling is on and there's a best path
+// to improve, otherwise a sample from the whole map
+func (p *PlannerBase) nextSamplePoint() geom.Coord {
+	if p.informedSampling && p.endNode != nil && len(p.BestPath) >= 2 {
+		if point, ok := p.nextInformedPoint(p.Root.Coord, p.endNode.Coord, p.endNode.CumulativeCost); ok {
+			return point
+		}
+	}
+	return p.nextHaltonPoint(p.width, p.height)
+}
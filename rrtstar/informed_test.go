@@ -0,0 +1,75 @@
+package rrtstar
+
+import (
+	"testing"
+
+	"github.com/brychanrobot/go-halton"
+	"github.com/skelterjohn/geom"
+)
+
+// unboundedCost can't promise any cost per distance, so it can't be sampled for
+type unboundedCost struct{}
+
+func (c unboundedCost) EdgeCost(p1, p2 *geom.Coord) float64 {
+	return euclideanDistance(p1, p2)
+}
+
+func informedTestPlanner(bestCost float64) *PlannerBase {
+	root := &Node{Coord: geom.Coord{X: 50, Y: 100}}
+	endNode := &Node{Coord: geom.Coord{X: 150, Y: 120}, CumulativeCost: bestCost}
+	return &PlannerBase{
+		Root:             root,
+		endNode:          endNode,
+		BestPath:         []*geom.Coord{&root.Coord, &endNode.Coord},
+		costFunction:     EuclideanCost{},
+		informedSampling: true,
+		width:            200,
+		height:           200,
+		mapArea:          200 * 200,
+		haltonX:          halton.NewHaltonSampler(19),
+		haltonY:          halton.NewHaltonSampler(23)}
+}
+
+// pathLengthThrough is the length of the shortest path from the root to the end node through point
+func pathLengthThrough(p *PlannerBase, point geom.Coord) float64 {
+	return euclideanDistance(&p.Root.Coord, &point) + euclideanDistance(&point, &p.endNode.Coord)
+}
+
+func TestInformedSamplesStayInTheEllipse(t *testing.T) {
+	p := informedTestPlanner(0)
+	p.endNode.CumulativeCost = 1.2 * euclideanDistance(&p.Root.Coord, &p.endNode.Coord)
+
+	for i := 0; i < 2000; i++ {
+		point := p.nextSamplePoint()
+		if length := pathLengthThrough(p, point); length > p.endNode.CumulativeCost+1e-9 {
+			t.Fatalf("sample %d at %v is on a path of %f, longer than the best %f", i, point, length, p.endNode.CumulativeCost)
+		}
+		if point.X < 0 || point.Y < 0 || point.X >= 200 || point.Y >= 200 {
+			t.Fatalf("sample %d at %v is off the map", i, point)
+		}
+	}
+}
+
+func TestInformedSamplingFallsBackToTheWholeMap(t *testing.T) {
+	straight := euclideanDistance(&geom.Coord{X: 50, Y: 100}, &geom.Coord{X: 150, Y: 120})
+	for _, test := range []struct {
+		name  string
+		p     *PlannerBase
+		setup func(p *PlannerBase)
+	}{
+		{"ellipse bigger than the map", informedTestPlanner(10 * straight), func(p *PlannerBase) {}},
+		{"unbounded cost", informedTestPlanner(1.2 * straight), func(p *PlannerBase) { p.costFunction = unboundedCost{} }},
+		{"no best path", informedTestPlanner(1.2 * straight), func(p *PlannerBase) { p.BestPath = nil }},
+	} {
+		test.setup(test.p)
+
+		outside := false
+		for i := 0; i < 200 && !outside; i++ {
+			point := test.p.nextSamplePoint()
+			outside = pathLengthThrough(test.p, point) > 1.2*straight
+		}
+		if !outside {
+			t.Errorf("%s: every sample was in the ellipse", test.name)
+		}
+	}
+}
@@ -25,6 +25,11 @@ type Node struct {
 	CumulativeCost float64
 	UnseenArea     float64
 	Status         Status
+	Heading        float64 // only used by planners that steer with dubins curves
+	// Coverage is the fraction of the open map seen on the way to the node, only kept by coverage planners
+	Coverage float64
+	seen     coverageMask
+	view     coverageMask
 }
 
 // AddChild adds a child and updates cost
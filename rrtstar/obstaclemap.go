@@ -0,0 +1,237 @@
+package rrtstar
+
+import (
+	"image"
+	"math"
+
+	"github.com/skelterjohn/geom"
+)
+
+// ObstacleMap answers collision queries for the planners and waldos
+type ObstacleMap interface {
+	// Bounds is the area of the map, everything outside it counts as an obstacle
+	Bounds() geom.Rect
+	// PointIntersects reports whether a node can't be placed at point
+	PointIntersects(point geom.Coord) bool
+	// SegmentIsFree reports whether the straight line from p1 to p2 misses every obstacle
+	SegmentIsFree(p1, p2 geom.Coord) bool
+}
+
+func boundsContain(bounds geom.Rect, point geom.Coord) bool {
+	return point.X >= bounds.Min.X && point.Y >= bounds.Min.Y && point.X < bounds.Max.X && point.Y < bounds.Max.Y
+}
+
+// RasterObstacleMap is an ObstacleMap over a grayscale image where bright pixels are obstacles
+type RasterObstacleMap struct {
+	Image *image.Gray
+	// FreeThreshold is the gray level below which a pixel may hold a node
+	FreeThreshold uint8
+	// ObstacleThreshold is the gray level above which a pixel blocks a segment
+	ObstacleThreshold uint8
+
+	radius float64 // how far the image has been inflated
+	// base is the image before any obstacles were painted on it, and the painted obstacles are kept so
+	// one can be cleared off without losing whatever else was under it
+	base            *image.Gray
+	paintedRects    []*geom.Rect
+	paintedPolygons []Polygon
+	paintedCircles  []*Circle
+}
+
+// NewRasterObstacleMap wraps an obstacle image with the thresholds the planners have always used
+func NewRasterObstacleMap(obstacleImage *image.Gray) *RasterObstacleMap {
+	return &RasterObstacleMap{
+		Image:             obstacleImage,
+		FreeThreshold:     defaultFreeThreshold,
+		ObstacleThreshold: defaultObstacleThreshold}
+}
+
+// Bounds returns the bounds of the image
+func (r *RasterObstacleMap) Bounds() geom.Rect {
+	bounds := r.Image.Bounds()
+	return geom.Rect{
+		Min: geom.Coord{X: float64(bounds.Min.X), Y: float64(bounds.Min.Y)},
+		Max: geom.Coord{X: float64(bounds.Max.X), Y: float64(bounds.Max.Y)}}
+}
+
+// PointIntersects reports whether the pixel under point is at least FreeThreshold
+func (r *RasterObstacleMap) PointIntersects(point geom.Coord) bool {
+	return !boundsContain(r.Bounds(), point) || r.Image.GrayAt(int(point.X), int(point.Y)).Y >= r.FreeThreshold
+}
+
+func (r *RasterObstacleMap) isPixelBlocked(x, y int) bool {
+	return r.Image.GrayAt(x, y).Y > r.ObstacleThreshold
+}
+
+// SegmentIsFree walks the supercover of the segment, every pixel it touches including both
+// pixels beside a corner it passes exactly through, looking for one above ObstacleThreshold
+// http://www.cse.yorku.ca/~amana/research/grid.pdf
+func (r *RasterObstacleMap) SegmentIsFree(p1, p2 geom.Coord) bool {
+	bounds := r.Bounds()
+	if !boundsContain(bounds, p1) || !boundsContain(bounds, p2) {
+		return false
+	}
+
+	x := int(math.Floor(p1.X))
+	y := int(math.Floor(p1.Y))
+	dx := p2.X - p1.X
+	dy := p2.Y - p1.Y
+
+	// t is how far along the segment we are, tMax is the t of the next pixel boundary in each axis
+	stepX, tDeltaX, tMaxX := 0, math.Inf(1), math.Inf(1)
+	if dx > 0 {
+		stepX, tDeltaX, tMaxX = 1, 1/dx, (math.Floor(p1.X)+1-p1.X)/dx
+	} else if dx < 0 {
+		stepX, tDeltaX, tMaxX = -1, -1/dx, (p1.X-math.Floor(p1.X))/-dx
+	}
+
+	stepY, tDeltaY, tMaxY := 0, math.Inf(1), math.Inf(1)
+	if dy > 0 {
+		stepY, tDeltaY, tMaxY = 1, 1/dy, (math.Floor(p1.Y)+1-p1.Y)/dy
+	} else if dy < 0 {
+		stepY, tDeltaY, tMaxY = -1, -1/dy, (p1.Y-math.Floor(p1.Y))/-dy
+	}
+
+	// tolerance for the rounding that builds up in tMax, so corners and the end aren't missed
+	const epsilon = 1e-9
+
+	for {
+		if r.isPixelBlocked(x, y) {
+			return false
+		}
+
+		if tMaxX > 1+epsilon && tMaxY > 1+epsilon {
+			return true
+		}
+
+		switch {
+		case tMaxX < tMaxY-epsilon:
+			x += stepX
+			tMaxX += tDeltaX
+		case tMaxY < tMaxX-epsilon:
+			y += stepY
+			tMaxY += tDeltaY
+		default:
+			// through a corner, so the pixels on either side are touched too
+			if r.isPixelBlocked(x+stepX, y) || r.isPixelBlocked(x, y+stepY) {
+				return false
+			}
+			x += stepX
+			y += stepY
+			tMaxX += tDeltaX
+			tMaxY += tDeltaY
+		}
+	}
+}
+
+// VectorObstacleMap is an ObstacleMap over lists of rectangles, polygons and circles. Its segment check is exact
+type VectorObstacleMap struct {
+	Rects    []*geom.Rect
+	Polygons []Polygon
+	Circles  []*Circle
+	bounds   geom.Rect
+	radius   float64 // how far the obstacles have been inflated
+}
+
+// NewVectorObstacleMap creates a width by height map holding rects, polygons and circles
+func NewVectorObstacleMap(width, height int, rects []*geom.Rect, polygons []Polygon, circles []*Circle) *VectorObstacleMap {
+	return &VectorObstacleMap{
+		Rects:    rects,
+		Polygons: polygons,
+		Circles:  circles,
+		bounds:   geom.Rect{Max: geom.Coord{X: float64(width), Y: float64(height)}}}
+}
+
+// Bounds returns the area of the map
+func (v *VectorObstacleMap) Bounds() geom.Rect {
+	return v.bounds
+}
+
+// PointIntersects reports whether point is outside the map or inside any (inflated) obstacle
+func (v *VectorObstacleMap) PointIntersects(point geom.Coord) bool {
+	if !boundsContain(v.bounds, point) {
+		return true
+	}
+
+	for _, rect := range v.Rects {
+		if v.radius > 0 && pointToRectangleDistance(point, rect) < v.radius || rectangleContainsPoint(*rect, point) {
+			return true
+		}
+	}
+
+	for _, polygon := range v.Polygons {
+		if v.radius > 0 && pointToPolygonDistance(point, polygon) < v.radius || polygon.ContainsPoint(point) {
+			return true
+		}
+	}
+
+	for _, circle := range v.Circles {
+		if euclideanDistance(&point, &circle.Coord) < circle.Radius+v.radius {
+			return true
+		}
+	}
+	return false
+}
+
+// SegmentIsFree reports whether the segment stays in the map without crossing the inside of any obstacle
+func (v *VectorObstacleMap) SegmentIsFree(p1, p2 geom.Coord) bool {
+	if !boundsContain(v.bounds, p1) || !boundsContain(v.bounds, p2) {
+		return false
+	}
+
+	for _, rect := range v.Rects {
+		if v.radius > 0 && segmentToRectangleDistance(p1, p2, rect) < v.radius || segmentIntersectsRectangle(p1, p2, rect) {
+			return false
+		}
+	}
+
+	for _, polygon := range v.Polygons {
+		if v.radius > 0 && segmentToPolygonDistance(p1, p2, polygon) < v.radius || polygon.IntersectsSegment(p1, p2) {
+			return false
+		}
+	}
+
+	for _, circle := range v.Circles {
+		if pointToSegmentDistance(circle.Coord, p1, p2) < circle.Radius+v.radius {
+			return false
+		}
+	}
+	return true
+}
+
+// segmentIntersectsRectangle clips the segment against the open rectangle with liang-barsky
+func segmentIntersectsRectangle(p1, p2 geom.Coord, rect *geom.Rect) bool {
+	dx := p2.X - p1.X
+	dy := p2.Y - p1.Y
+
+	tMin := 0.0
+	tMax := 1.0
+	clip := func(p, q float64) bool {
+		if p == 0 {
+			// parallel to this edge, so it's either always inside or always outside of it
+			return q > 0
+		}
+		t := q / p
+		if p < 0 {
+			tMin = math.Max(tMin, t)
+		} else {
+			tMax = math.Min(tMax, t)
+		}
+		return tMin < tMax
+	}
+
+	return clip(-dx, p1.X-rect.Min.X) &&
+		clip(dx, rect.Max.X-p1.X) &&
+		clip(-dy, p1.Y-rect.Min.Y) &&
+		clip(dy, rect.Max.Y-p1.Y)
+}
+
+// NewObstacleMap picks the exact vector map when the obstacle shapes are known and falls back to
+// the raster map when all there is to go on is the image
+func NewObstacleMap(obstacleImage *image.Gray, obstacleRects []*geom.Rect, obstaclePolygons []Polygon, obstacleCircles []*Circle) ObstacleMap {
+	if len(obstacleRects) > 0 || len(obstaclePolygons) > 0 || len(obstacleCircles) > 0 {
+		bounds := obstacleImage.Bounds()
+		return NewVectorObstacleMap(bounds.Dx(), bounds.Dy(), obstacleRects, obstaclePolygons, obstacleCircles)
+	}
+	return NewRasterObstacleMap(obstacleImage)
+}
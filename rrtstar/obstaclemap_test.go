@@ -0,0 +1,140 @@
+package rrtstar
+
+import (
+	"image"
+	"testing"
+
+	"github.com/skelterjohn/geom"
+)
+
+// paintRects fills the pixels under rects on a width by height image
+func paintRects(width, height int, rects []*geom.Rect) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for _, rect := range rects {
+		for y := int(rect.Min.Y); y < int(rect.Max.Y); y++ {
+			for x := int(rect.Min.X); x < int(rect.Max.X); x++ {
+				img.Pix[img.PixOffset(x, y)] = 255
+			}
+		}
+	}
+	return img
+}
+
+func TestRasterAndVectorMapsAgree(t *testing.T) {
+	rects := []*geom.Rect{
+		{Min: geom.Coord{X: 20, Y: 30}, Max: geom.Coord{X: 50, Y: 45}},
+		{Min: geom.Coord{X: 70, Y: 10}, Max: geom.Coord{X: 90, Y: 80}}}
+	maps := map[string]ObstacleMap{
+		"raster": NewRasterObstacleMap(paintRects(100, 100, rects)),
+		"vector": NewVectorObstacleMap(100, 100, rects, nil, nil)}
+
+	for name, obstacleMap := range maps {
+		if bounds := obstacleMap.Bounds(); bounds.Min != (geom.Coord{}) || bounds.Max != (geom.Coord{X: 100, Y: 100}) {
+			t.Errorf("%s: bounds are %v", name, bounds)
+		}
+		for _, point := range []geom.Coord{{X: -1, Y: 50}, {X: 50, Y: -0.5}, {X: 100, Y: 50}, {X: 50, Y: 100}} {
+			if !obstacleMap.PointIntersects(point) {
+				t.Errorf("%s: %v is off the map but isn't blocked", name, point)
+			}
+		}
+		if obstacleMap.SegmentIsFree(geom.Coord{X: 50, Y: 50}, geom.Coord{X: 50, Y: 120}) {
+			t.Errorf("%s: a segment leaving the map is free", name)
+		}
+	}
+
+	// pixel centres are never on the edge of a rect, so the maps should see them the same
+	for y := 0.5; y < 100; y++ {
+		for x := 0.5; x < 100; x++ {
+			point := geom.Coord{X: x, Y: y}
+			if maps["raster"].PointIntersects(point) != maps["vector"].PointIntersects(point) {
+				t.Fatalf("the maps disagree about %v", point)
+			}
+		}
+	}
+
+	for _, segment := range []struct {
+		p1, p2 geom.Coord
+		free   bool
+	}{
+		{geom.Coord{X: 5, Y: 35}, geom.Coord{X: 60, Y: 40}, false},
+		{geom.Coord{X: 5, Y: 5}, geom.Coord{X: 95, Y: 5}, true},
+		{geom.Coord{X: 60, Y: 90}, geom.Coord{X: 95, Y: 50}, false},
+		{geom.Coord{X: 5, Y: 50}, geom.Coord{X: 65, Y: 90}, true},
+	} {
+		for name, obstacleMap := range maps {
+			if obstacleMap.SegmentIsFree(segment.p1, segment.p2) != segment.free {
+				t.Errorf("%s: the segment from %v to %v should be free: %v", name, segment.p1, segment.p2, segment.free)
+			}
+		}
+	}
+}
+
+func TestRasterSegmentsCheckEveryPixelTheyTouch(t *testing.T) {
+	blocked := func(pixels ...image.Point) *RasterObstacleMap {
+		img := image.NewGray(image.Rect(0, 0, 30, 30))
+		for _, pixel := range pixels {
+			img.Pix[img.PixOffset(pixel.X, pixel.Y)] = 255
+		}
+		return NewRasterObstacleMap(img)
+	}
+	var antidiagonal []image.Point
+	for i := 0; i < 20; i++ {
+		antidiagonal = append(antidiagonal, image.Point{X: i, Y: 19 - i})
+	}
+
+	for _, test := range []struct {
+		name   string
+		raster *RasterObstacleMap
+		p1, p2 geom.Coord
+		free   bool
+	}{
+		// y = x goes through the corner at (11, 11), where it touches the pixels beside it
+		{"grazing a corner", blocked(image.Point{X: 11, Y: 10}), geom.Coord{X: 5, Y: 5}, geom.Coord{X: 15, Y: 15}, false},
+		{"grazing the other side of a corner", blocked(image.Point{X: 10, Y: 11}), geom.Coord{X: 15, Y: 15}, geom.Coord{X: 5, Y: 5}, false},
+		{"crossing a wall a pixel thick", blocked(antidiagonal...), geom.Coord{X: 0.5, Y: 0.5}, geom.Coord{X: 19.5, Y: 19.5}, false},
+		{"crossing a pixel by a sliver", blocked(image.Point{X: 12, Y: 10}), geom.Coord{X: 5, Y: 9.5}, geom.Coord{X: 25, Y: 10.82}, false},
+		{"passing beside a row", blocked(image.Point{X: 8, Y: 11}, image.Point{X: 9, Y: 11}, image.Point{X: 10, Y: 11}), geom.Coord{X: 5, Y: 10.5}, geom.Coord{X: 15, Y: 10.5}, true},
+		{"passing beside a corner", blocked(image.Point{X: 12, Y: 10}), geom.Coord{X: 5, Y: 5}, geom.Coord{X: 15, Y: 15}, true},
+		{"staying in one pixel", blocked(image.Point{X: 3, Y: 3}), geom.Coord{X: 10.1, Y: 10.1}, geom.Coord{X: 10.9, Y: 10.8}, true},
+	} {
+		if free := test.raster.SegmentIsFree(test.p1, test.p2); free != test.free {
+			t.Errorf("%s: the segment from %v to %v should be free: %v", test.name, test.p1, test.p2, test.free)
+		}
+	}
+}
+
+func TestVectorSegmentsClipAgainstTheInsideOfRects(t *testing.T) {
+	rect := &geom.Rect{Min: geom.Coord{X: 10, Y: 10}, Max: geom.Coord{X: 20, Y: 20}}
+	for _, test := range []struct {
+		name   string
+		p1, p2 geom.Coord
+		blocks bool
+	}{
+		{"crossing", geom.Coord{X: 0, Y: 15}, geom.Coord{X: 30, Y: 15}, true},
+		{"inside", geom.Coord{X: 12, Y: 12}, geom.Coord{X: 18, Y: 17}, true},
+		{"ending inside", geom.Coord{X: 0, Y: 0}, geom.Coord{X: 11, Y: 11}, true},
+		{"through opposite corners", geom.Coord{X: 5, Y: 5}, geom.Coord{X: 25, Y: 25}, true},
+		{"along an edge", geom.Coord{X: 0, Y: 10}, geom.Coord{X: 30, Y: 10}, false},
+		{"grazing a corner", geom.Coord{X: 10, Y: 30}, geom.Coord{X: 30, Y: 10}, false},
+		{"ending on an edge", geom.Coord{X: 0, Y: 15}, geom.Coord{X: 10, Y: 15}, false},
+		{"beside", geom.Coord{X: 5, Y: 0}, geom.Coord{X: 5, Y: 30}, false},
+		{"stopping short", geom.Coord{X: 0, Y: 0}, geom.Coord{X: 9, Y: 9}, false},
+	} {
+		if blocks := segmentIntersectsRectangle(test.p1, test.p2, rect); blocks != test.blocks {
+			t.Errorf("%s: the segment from %v to %v should cross the rect: %v", test.name, test.p1, test.p2, test.blocks)
+		}
+		if blocks := segmentIntersectsRectangle(test.p2, test.p1, rect); blocks != test.blocks {
+			t.Errorf("%s reversed: the segment from %v to %v should cross the rect: %v", test.name, test.p2, test.p1, test.blocks)
+		}
+	}
+}
+
+func TestNewObstacleMapPrefersVectors(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 50, 50))
+	if _, ok := NewObstacleMap(img, []*geom.Rect{{Max: geom.Coord{X: 5, Y: 5}}}, nil, nil).(*VectorObstacleMap); !ok {
+		t.Error("a map with rects isn't checked with vectors")
+	}
+	if _, ok := NewObstacleMap(img, nil, nil, nil).(*RasterObstacleMap); !ok {
+		t.Error("a map with only an image isn't checked with its pixels")
+	}
+}
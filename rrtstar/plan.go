@@ -0,0 +1,109 @@
+package rrtstar
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/skelterjohn/geom"
+)
+
+// PlanBudget limits how long Plan samples for. Fields left at zero don't limit anything
+type PlanBudget struct {
+	// Duration stops planning this long after it starts, as well as any deadline on the context
+	Duration time.Duration
+	// Iterations stops planning after this many samples
+	Iterations int
+	// ConvergenceTolerance stops planning once a path has been found and the best cost improves by
+	// less than this fraction over ConvergenceWindow samples
+	ConvergenceTolerance float64
+	ConvergenceWindow    int
+}
+
+// PathUpdate is the best path Plan has at some iteration. The path is a copy, so it stays as it is
+// while planning carries on. It's empty with an infinite cost when the goal can't be reached
+type PathUpdate struct {
+	Path      []*geom.Coord
+	Cost      float64
+	Iteration int
+}
+
+// copyPath copies the points of a path so it doesn't change with the tree
+func copyPath(path []*geom.Coord) []*geom.Coord {
+	points := make([]geom.Coord, len(path))
+	copied := make([]*geom.Coord, len(path))
+	for i, point := range path {
+		points[i] = *point
+		copied[i] = &points[i]
+	}
+	return copied
+}
+
+// samePath reports whether two paths go through the same points
+func samePath(a, b []*geom.Coord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if *a[i] != *b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Plan samples planner on its own goroutine until ctx is done or the budget runs out. Whenever the
+// best path changes, whether it got cheaper or an obstacle or the goal moved, the latest one is left
+// on the returned channel in place of any the caller hasn't read yet, so sampling never waits on the
+// caller. The channel is closed when planning stops, and the last update read from it is the best
+// path. The planner can still be read and changed while it plans
+func Plan(ctx context.Context, planner Planner, budget PlanBudget) <-chan PathUpdate {
+	updates := make(chan PathUpdate, 1)
+	publish := func(update PathUpdate) {
+		// only this goroutine sends, so once the old update is out of the way there's room
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- update
+	}
+
+	go func() {
+		defer close(updates)
+
+		if budget.Duration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, budget.Duration)
+			defer cancel()
+		}
+
+		last := PathUpdate{Cost: math.Inf(1)}
+		windowCost := math.Inf(1)
+		for i := 1; budget.Iterations <= 0 || i <= budget.Iterations; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			planner.Sample()
+
+			path, cost := planner.GetBestPathAndCost()
+			if cost != last.Cost || !samePath(path, last.Path) {
+				last = PathUpdate{Path: path, Cost: cost, Iteration: i}
+				publish(last)
+			}
+
+			if budget.ConvergenceWindow > 0 && i%budget.ConvergenceWindow == 0 {
+				// a window that found the first path, or whose path got dearer because the map
+				// changed, hasn't converged on anything yet
+				if !math.IsInf(windowCost, 1) && cost <= windowCost && windowCost-cost <= budget.ConvergenceTolerance*windowCost {
+					return
+				}
+				windowCost = cost
+			}
+		}
+	}()
+
+	return updates
+}
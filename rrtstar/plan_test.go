@@ -0,0 +1,139 @@
+package rrtstar
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/skelterjohn/geom"
+)
+
+// scriptedPlanner has the best cost it's given for each sample and counts how many it took. It
+// leaves everything Plan doesn't use to the nil Planner
+type scriptedPlanner struct {
+	Planner
+	samples int
+	cost    func(sample int) float64
+}
+
+func (s *scriptedPlanner) Sample() {
+	s.samples++
+}
+
+func (s *scriptedPlanner) GetBestPathAndCost() ([]*geom.Coord, float64) {
+	cost := s.cost(s.samples)
+	if math.IsInf(cost, 1) {
+		return nil, cost
+	}
+	return []*geom.Coord{{X: 0, Y: 0}, {X: cost, Y: 0}}, cost
+}
+
+// drain reads every update until the channel is closed, failing if it takes too long
+func drain(t *testing.T, updates <-chan PathUpdate) (last PathUpdate, count int) {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return last, count
+			}
+			last = update
+			count++
+		case <-timeout:
+			t.Fatal("planning didn't stop")
+		}
+	}
+}
+
+func TestPlanStopsOnConvergence(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		cost    func(sample int) float64
+		samples int
+	}{
+		// the window that found the path and the one that improved by 9% don't count
+		{"once the cost settles", func(sample int) float64 {
+			switch {
+			case sample < 150:
+				return math.Inf(1)
+			case sample < 250:
+				return 110
+			default:
+				return 100.5
+			}
+		}, 400},
+		// nor does the one where the path got dearer
+		{"not while the cost goes up", func(sample int) float64 {
+			switch {
+			case sample < 150:
+				return math.Inf(1)
+			case sample < 290:
+				return 100
+			default:
+				return 150
+			}
+		}, 400},
+		{"not while there's no path", func(sample int) float64 { return math.Inf(1) }, 1000},
+	} {
+		planner := &scriptedPlanner{cost: test.cost}
+		last, _ := drain(t, Plan(context.Background(), planner, PlanBudget{
+			Iterations: 1000, ConvergenceWindow: 100, ConvergenceTolerance: 0.01}))
+		if planner.samples != test.samples {
+			t.Errorf("%s: stopped after %d samples, expected %d", test.name, planner.samples, test.samples)
+		}
+		if cost := test.cost(test.samples); last.Cost != cost && !(math.IsInf(cost, 1) && last.Cost == 0) {
+			t.Errorf("%s: the last update costs %f, expected %f", test.name, last.Cost, cost)
+		}
+	}
+}
+
+func TestPlanStopsAtTheIterationLimit(t *testing.T) {
+	planner := &scriptedPlanner{cost: func(sample int) float64 { return 1000 / float64(sample) }}
+	last, count := drain(t, Plan(context.Background(), planner, PlanBudget{Iterations: 300}))
+	if planner.samples != 300 {
+		t.Errorf("took %d samples, expected 300", planner.samples)
+	}
+	// every sample changes the cost, so the last update is from the last one
+	if last.Iteration != 300 || last.Cost != 1000.0/300 {
+		t.Errorf("the last update is from iteration %d and costs %f", last.Iteration, last.Cost)
+	}
+	if count > 300 {
+		t.Errorf("%d updates for 300 samples", count)
+	}
+}
+
+func TestPlanStopsWhenCancelled(t *testing.T) {
+	rects := []*geom.Rect{{Min: geom.Coord{X: 95, Y: 40}, Max: geom.Coord{X: 105, Y: 190}}}
+	rrtStar, err := NewRrtStar(NewVectorObstacleMap(200, 200, rects, nil, nil), rects, dynamicTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := Plan(ctx, rrtStar, PlanBudget{})
+	first, ok := <-updates
+	if !ok {
+		t.Fatal("planning stopped before finding a path")
+	}
+	cancel()
+	last, count := drain(t, updates)
+	if count == 0 {
+		last = first
+	}
+
+	path, cost := rrtStar.GetBestPathAndCost()
+	if last.Cost != cost || !samePath(last.Path, path) {
+		t.Errorf("the last update costs %f but the best path %f", last.Cost, cost)
+	}
+
+	// the path handed out stays as it was while the planner carries on
+	before := copyPath(path)
+	last, _ = drain(t, Plan(context.Background(), rrtStar, PlanBudget{Duration: 50 * time.Millisecond}))
+	if !samePath(path, before) {
+		t.Error("a path handed out changed while planning")
+	}
+	if path, cost = rrtStar.GetBestPathAndCost(); last.Path != nil && (last.Cost != cost || !samePath(last.Path, path)) {
+		t.Errorf("after a deadline the last update costs %f but the best path %f", last.Cost, cost)
+	}
+}
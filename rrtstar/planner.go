@@ -3,9 +3,9 @@ package rrtstar
 import (
 	"image"
 	"image/png"
-	"log"
 	"math"
 	"os"
+	"sync"
 
 	halton "github.com/brychanrobot/go-halton"
 	"github.com/brychanrobot/go-rrt-star/viewshed"
@@ -14,30 +14,40 @@ import (
 	"github.com/skelterjohn/geom"
 )
 
-const (
-	unseenK   = 4.0
-	distanceK = 0.00
-)
-
 type Planner interface {
 	GetRoot() *Node
+	WalkTree(visit func(parent, child *Node))
 	GetStartPoint() *geom.Coord
 	GetEndPoint() *geom.Coord
 	GetBestPath() []*geom.Coord
+	GetBestPathCost() float64
+	GetBestPathAndCost() ([]*geom.Coord, float64)
+	GetBestPathCoverage() float64
 	GetViewshed() *viewshed.Viewshed
 	GetIsAddingNodes() bool
+	GetIsGoalReachable() bool
 	GetNumNodes() uint64
 
 	Sample()
+	ShortcutPath(path []*geom.Coord) []*geom.Coord
+	RandomShortcutPath(path []*geom.Coord, iterations int) []*geom.Coord
+	SmoothPath(path []*geom.Coord, samplesPerSegment int) []*geom.Coord
 	RenderUnseenCostMap(filename string)
 	MoveStartPoint(dx, dy float64)
 	MoveEndPoint(dx, dy float64)
+	AddObstacle(obstacle Obstacle) error
+	RemoveObstacle(obstacle Obstacle) error
 }
 
 type PlannerBase struct {
-	obstacleImage      *image.Gray
-	obstacleRects      []*geom.Rect
-	rtree              *rtreego.Rtree
+	obstacleMap      ObstacleMap
+	obstacleRects    []*geom.Rect
+	obstaclePolygons []Polygon
+	obstacleCircles  []*Circle
+	rtree            *rtreego.Rtree
+	// reversedRtree holds a tree that's driven toward its root, so its edges cost what it takes to
+	// get from child to parent
+	reversedRtree      *rtreego.Rtree
 	Root               *Node
 	StartPoint         *geom.Coord
 	EndPoint           *geom.Coord
@@ -50,56 +60,140 @@ type PlannerBase struct {
 	BestPath           []*geom.Coord
 	Viewshed           viewshed.Viewshed
 	nodeThreshold      uint64
+	informedSampling   bool
 	IsAddingNodes      bool
 	NumNodes           uint64
 	haltonX            *halton.HaltonSampler
 	haltonY            *halton.HaltonSampler
-	unseenAreaMap      map[geom.Coord]float64
-	obstacleArea       float64
+	costFunction       CostFunction
+	coverage           *coverageGrid
+	vehicle            vehicle
+	// uncheckedHop hangs from its parent without the edge between them having been checked
+	uncheckedHop *Node
+	// lock is held for writing while the tree changes, so the getters can be called from other goroutines
+	lock *sync.RWMutex
 }
 
 //Getters
+
+// GetRoot returns a copy of the tree, so it can be walked while sampling carries on
 func (p *PlannerBase) GetRoot() *Node {
-	return p.Root
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return copyTree(p.Root)
+}
+
+// WalkTree calls visit with every edge in the tree while holding the lock, which is cheaper than
+// GetRoot when the tree is only looked at. visit mustn't keep the nodes or call back into the planner
+func (p *PlannerBase) WalkTree(visit func(parent, child *Node)) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	walkEdges(p.Root, visit)
 }
 
 func (p *PlannerBase) GetStartPoint() *geom.Coord {
-	return p.StartPoint
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	startPoint := *p.StartPoint
+	return &startPoint
 }
 
 func (p *PlannerBase) GetEndPoint() *geom.Coord {
-	return p.EndPoint
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	endPoint := *p.EndPoint
+	return &endPoint
 }
 
+// GetBestPath returns a copy of the best path, which doesn't change as the tree does
 func (p *PlannerBase) GetBestPath() []*geom.Coord {
-	return p.BestPath
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return copyPath(p.BestPath)
 }
 
+func (p *PlannerBase) bestPathCost() float64 {
+	if p.endNode == nil || len(p.BestPath) < 2 {
+		return math.Inf(1)
+	}
+	return p.endNode.CumulativeCost
+}
+
+// bestPathCoverage sweeps from every point of the best path
+func (p *PlannerBase) bestPathCoverage() float64 {
+	if p.coverage == nil || len(p.BestPath) == 0 {
+		return 0
+	}
+
+	seen := p.coverage.newMask()
+	if p.Viewshed.Sensor.IsDirectional() {
+		// the path runs from the end back to the start, and the camera faces the way the robot
+		// arrived at each point, or at the start the way it leaves
+		shed := p.Viewshed.Clone()
+		for i, point := range p.BestPath {
+			if i+1 < len(p.BestPath) {
+				shed.Sensor.Heading = angleBetweenPoints(*p.BestPath[i+1], *point)
+			} else if i > 0 {
+				shed.Sensor.Heading = angleBetweenPoints(*point, *p.BestPath[i-1])
+			}
+			shed.UpdateCenterLocation(point.X, point.Y)
+			shed.Sweep()
+			seen = seen.union(p.coverage.rasterize(shed.ViewablePolygon), seen)
+		}
+	} else {
+		centers := make([]geom.Coord, len(p.BestPath))
+		for i, point := range p.BestPath {
+			centers[i] = *point
+		}
+		for _, polygon := range p.Viewshed.SweepPolygons(centers, 0) {
+			seen = seen.union(p.coverage.rasterize(polygon), seen)
+		}
+	}
+	return p.coverage.fraction(seen.count()) * 100
+}
+
+// GetViewshed returns a copy of the planner's viewshed that can be moved and swept without touching
+// the planner
 func (p *PlannerBase) GetViewshed() *viewshed.Viewshed {
-	return &p.Viewshed
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.Viewshed.Clone()
 }
 
 func (p *PlannerBase) GetIsAddingNodes() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	return p.IsAddingNodes
 }
 
 func (p *PlannerBase) GetNumNodes() uint64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	return p.NumNodes
 }
 
 func (p *PlannerBase) RenderUnseenCostMap(filename string) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
 	costMap := mat64.NewDense(p.height, p.width, nil)
 	costMapImg := image.NewGray(image.Rect(0, 0, p.width, p.height))
 
+	// every open pixel gets its own sweep, so they're spread across all the cpus
+	var points []geom.Coord
 	for row := 0; row < p.height; row++ {
 		for col := 0; col < p.width; col++ {
-			if p.obstacleImage.GrayAt(col, row).Y < 200 {
-				point := geom.Coord{X: float64(col), Y: float64(row)}
-				costMap.Set(row, col, p.getViewArea(&point))
+			point := geom.Coord{X: float64(col), Y: float64(row)}
+			if !p.obstacleMap.PointIntersects(point) {
+				points = append(points, point)
 			}
 		}
 	}
 
+	for i, area := range p.Viewshed.SweepAreas(points, 0) {
+		costMap.Set(int(points[i].Y), int(points[i].X), area)
+	}
+
 	costMap.Scale(255/mat64.Max(costMap), costMap)
 
 	for row := 0; row < p.height; row++ {
@@ -117,6 +211,9 @@ func (p *PlannerBase) RenderUnseenCostMap(filename string) {
 
 func (p *PlannerBase) traceBestPath() {
 	p.BestPath = p.BestPath[:0]
+	if !p.isGoalReachable() {
+		return
+	}
 	currentNode := p.endNode
 	for currentNode != nil {
 		p.BestPath = append(p.BestPath, &currentNode.Coord)
@@ -130,57 +227,32 @@ func (p *PlannerBase) getViewArea(point *geom.Coord) float64 {
 	return viewshed.Area2DPolygon(p.Viewshed.ViewablePolygon)
 }
 
-func (p *PlannerBase) getUnseenArea(point *geom.Coord) float64 {
-	//return (p.mapArea - p.getViewArea(point)) / p.mapArea
-	value := p.unseenAreaMap[*point]
-	if value == 0 {
-		value = (p.mapArea - p.obstacleArea - p.getViewArea(point)) / (p.mapArea + p.obstacleArea)
-		p.unseenAreaMap[*point] = value
+func (p *PlannerBase) getPointCost(point *geom.Coord) float64 {
+	if pointCost, ok := p.costFunction.(PointCostFunction); ok {
+		return pointCost.PointCost(point)
 	}
-
-	return value
+	return 0
 }
 
-func (p *PlannerBase) getEdgeUnseenArea(p1, p2 *geom.Coord) (float64, float64) {
-	/*angle := angleBetweenPoints(*p1, *p2)
-	dist := euclideanDistance(p1, p2)
-	sum := 0.0
-	for i := 5.0; i < dist; i += 5 {
-		x := p.maxSegment*math.Cos(angle) + p1.X
-		y := p.maxSegment*math.Sin(angle) + p1.Y
-		point := geom.Coord{X: x, Y: y}
-		value := p.unseenAreaMap[point]
-		if value == 0 {
-			value = p.getUnseenArea(&point)
-			p.unseenAreaMap[point] = value
-		}
+func (p *PlannerBase) getCost(neighbor *geom.Coord, point *geom.Coord) float64 {
+	return p.costFunction.EdgeCost(neighbor, point)
+}
 
-		sum += value
+// getCostIn is the cost of the edge from parent to child in rtree, taken the way it's driven
+func (p *PlannerBase) getCostIn(rtree *rtreego.Rtree, parent *geom.Coord, child *geom.Coord) float64 {
+	if rtree == p.reversedRtree {
+		return p.getCost(child, parent)
 	}
-	return sum
-	*/
-
-	dist := euclideanDistance(p1, p2)
-
-	a1 := p.getUnseenArea(p1)
-	//am := p.getUnseenArea(&geom.Coord{X: float64(int((p1.X + p2.X) / 2.0)), Y: float64(int((p1.Y + p2.Y) / 2.0))})
-	a2 := p.getUnseenArea(p2)
-
-	unseenArea := ((a1 + a2) / 2.0) * dist
-
-	return unseenArea, dist
+	return p.getCost(parent, child)
 }
 
-func (p *PlannerBase) getCost(neighbor *geom.Coord, point *geom.Coord) float64 {
-
-	unseenArea, dist := p.getEdgeUnseenArea(neighbor, point)
-	//dist := euclideanDistance(neighbor, point)
-	return dist*distanceK + unseenArea*unseenK
+func (p *PlannerBase) getBestNeighbor(point *geom.Coord, neighborhoodSize float64) (*Node, float64, []*Node, []float64) {
+	return p.getBestNeighborIn(p.rtree, point, neighborhoodSize)
 }
 
-func (p *PlannerBase) getBestNeighbor(point *geom.Coord, neighborhoodSize float64) (*Node, float64, []*Node, []float64) {
+func (p *PlannerBase) getBestNeighborIn(rtree *rtreego.Rtree, point *geom.Coord, neighborhoodSize float64) (*Node, float64, []*Node, []float64) {
 	rtreePoint := rtreego.Point{point.X, point.Y}
-	spatialNeighbors := p.rtree.SearchIntersect(rtreePoint.ToRect(neighborhoodSize))
+	spatialNeighbors := rtree.SearchIntersect(rtreePoint.ToRect(neighborhoodSize))
 	neighborCosts := []float64{}
 	neighbors := []*Node{}
 	bestCost := math.MaxFloat64
@@ -188,9 +260,9 @@ func (p *PlannerBase) getBestNeighbor(point *geom.Coord, neighborhoodSize float6
 	var bestNeighbor *Node
 	for _, spatialNeighbor := range spatialNeighbors {
 		neighbor := spatialNeighbor.(*Node)
-		if neighbor.Coord != *point && !p.lineIntersectsObstacle(*point, neighbor.Coord, 200) {
+		if neighbor.Coord != *point && !p.lineIntersectsObstacle(*point, neighbor.Coord) {
 			neighbors = append(neighbors, neighbor)
-			cost := p.getCost(&neighbor.Coord, point)
+			cost := p.getCostIn(rtree, &neighbor.Coord, point)
 			neighborCosts = append(neighborCosts, cost)
 			if cost+neighbor.CumulativeCost < bestCumulativeCost {
 				bestCost = cost
@@ -203,50 +275,97 @@ func (p *PlannerBase) getBestNeighbor(point *geom.Coord, neighborhoodSize float6
 	return bestNeighbor, bestCost, neighbors, neighborCosts
 }
 
-func (p *PlannerBase) MoveStartPoint(dx, dy float64) {
+// moveStartPoint takes the root to the moved start without adding a node
+func (p *PlannerBase) moveStartPoint(dx, dy float64) {
 	if dx != 0 || dy != 0 {
 		p.StartPoint.X += dx
 		p.StartPoint.Y += dy
-		//log.Println(p.StartPoint)
-		newRoot := &Node{parent: nil, Coord: *p.StartPoint, CumulativeCost: 0}
-		p.NumNodes++
-		//newRoot.UnseenArea = p.getUnseenArea(&newRoot.Coord)
-		p.rtree.Insert(newRoot)
-
-		p.Root.Rewire(newRoot, p.getCost(&newRoot.Coord, &p.Root.Coord))
-		p.Root = newRoot
-
-		_, _, neighbors, neighborCosts := p.getBestNeighbor(&p.Root.Coord, p.rewireNeighborhood*1.5)
-		for i, neighbor := range neighbors {
-			if !p.lineIntersectsObstacle(p.Root.Coord, neighbor.Coord, 200) {
-				if neighborCosts[i]+p.Root.CumulativeCost < neighbor.CumulativeCost {
-					neighbor.Rewire(p.Root, neighborCosts[i])
-				}
+
+		edges := p.straightEdges()
+		p.forgetRemoved(p.moveRoot(*p.StartPoint, edges))
+		p.rewireNeighbors(p.rtree, p.Root, p.Root, edges)
+		p.traceBestPath()
+	}
+}
+
+// extendTree steers from the nearest node in rtree toward point and adds a node there with rrt* rewiring.
+// It returns nil if no node could be added
+func (p *PlannerBase) extendTree(rtree *rtreego.Rtree, point geom.Coord) *Node {
+	nnSpatial := rtree.NearestNeighbor(rtreego.Point{point.X, point.Y})
+	nn := nnSpatial.(*Node)
+
+	//cost, unseenArea := r.getCost(&nn.Point, &point)
+	dist := euclideanDistance(&nn.Coord, &point)
+
+	//log.Println(dist)
+
+	if dist > p.maxSegment {
+		angle := angleBetweenPoints(nn.Coord, point)
+		x := p.maxSegment*math.Cos(angle) + nn.Coord.X
+		y := p.maxSegment*math.Sin(angle) + nn.Coord.Y
+		point = geom.Coord{X: x, Y: y}
+	}
+
+	if p.obstacleMap.PointIntersects(point) {
+		return nil
+	}
+
+	//unseenArea := (r.mapArea - r.getViewArea(&point)) / r.mapArea
+	bestNeighbor, bestCost, neighbors, _ := p.getBestNeighborIn(rtree, &point, p.rewireNeighborhood)
+
+	if bestNeighbor == nil { //!r.lineIntersectsObstacle(point, bestNeighbor.Point, 200) {
+		return nil
+	}
+
+	//unseenArea := (r.mapArea - r.getViewArea(&point)) / r.mapArea
+	newNode := bestNeighbor.AddAndCreateChild(point, bestCost, 0.0)
+	p.NumNodes++
+	rtree.Insert(newNode)
+
+	for _, neighbor := range neighbors {
+		//neighbor := neighborInterface.(*Node)
+		if neighbor != bestNeighbor && !p.lineIntersectsObstacle(newNode.Coord, neighbor.Coord) {
+			// the neighbor's cost was to the new node, rewiring goes the other way
+			cost := p.getCostIn(rtree, &newNode.Coord, &neighbor.Coord)
+			if cost+newNode.CumulativeCost < neighbor.CumulativeCost {
+				neighbor.Rewire(newNode, cost)
+			}
+		}
+	}
+
+	return newNode
+}
+
+// rewireTree rewires the neighborhood of point in rtree through its best node without adding any nodes
+func (p *PlannerBase) rewireTree(rtree *rtreego.Rtree, point geom.Coord) {
+	bestNeighbor, _, neighbors, _ := p.getBestNeighborIn(rtree, &point, float64(p.rewireNeighborhood))
+	if bestNeighbor == nil {
+		return
+	}
+	for _, neighbor := range neighbors {
+		if neighbor != bestNeighbor && !p.lineIntersectsObstacle(bestNeighbor.Coord, neighbor.Coord) {
+			cost := p.getCostIn(rtree, &bestNeighbor.Coord, &neighbor.Coord)
+			if cost+bestNeighbor.CumulativeCost < neighbor.CumulativeCost {
+				neighbor.Rewire(bestNeighbor, cost)
 			}
 		}
 	}
 }
 
-func (p *PlannerBase) MoveEndPoint(dx, dy float64) {
+// moveEndPoint moves the goal node with the end point and joins it to the best neighbor that can get
+// to it, growing the tree toward it when none can
+func (p *PlannerBase) moveEndPoint(dx, dy float64) {
 	if dx != 0 || dy != 0 {
 		p.EndPoint.X += dx
 		p.EndPoint.Y += dy
-
-		bestNeighbor, bestCost, _, _ := p.getBestNeighbor(p.EndPoint, p.rewireNeighborhood)
-
-		if bestNeighbor != nil {
-			p.endNode = bestNeighbor.AddAndCreateChild(*p.EndPoint, bestCost, 0.0)
-			p.NumNodes++
-
-			p.rtree.Insert(p.endNode)
-		} else {
-			p.EndPoint.X -= dx
-			p.EndPoint.Y -= dy
-		}
+		p.trackGoal(p.rtree, p.straightEdges(), p.extendTree)
 	}
 }
 
 func (p *PlannerBase) Prune(minorAxisSquares int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
 	var squareSize int
 	if p.height < p.width {
 		squareSize = int(p.height / minorAxisSquares)
@@ -254,14 +373,15 @@ func (p *PlannerBase) Prune(minorAxisSquares int) {
 		squareSize = int(p.width / minorAxisSquares)
 	}
 
-	log.Printf("sq: %d", squareSize)
-
 	for cy := int(squareSize / 2); cy < p.height; cy += squareSize {
 		for cx := int(squareSize / 2); cx < p.width; cx += squareSize {
 			cPoint := geom.Coord{X: float64(cx), Y: float64(cy)}
 			bestNeighbor, _, neighbors, _ := p.getBestNeighbor(&cPoint, float64(squareSize))
+			if bestNeighbor == nil {
+				continue
+			}
 			for _, neighbor := range neighbors {
-				if neighbor != bestNeighbor && !p.lineIntersectsObstacle(bestNeighbor.Coord, neighbor.Coord, 200) {
+				if neighbor != bestNeighbor && !p.lineIntersectsObstacle(bestNeighbor.Coord, neighbor.Coord) {
 					cost := p.getCost(&bestNeighbor.Coord, &neighbor.Coord)
 					if cost+bestNeighbor.CumulativeCost < neighbor.CumulativeCost {
 						neighbor.Rewire(bestNeighbor, cost)
@@ -270,7 +390,8 @@ func (p *PlannerBase) Prune(minorAxisSquares int) {
 			}
 
 			for _, neighbor := range neighbors {
-				if len(neighbor.Children) == 0 {
+				// the root and the goal are leaves too, but the tree can't do without them
+				if len(neighbor.Children) == 0 && neighbor != p.Root && neighbor != p.endNode {
 					neighbor.parent.RemoveChild(neighbor)
 					p.rtree.Delete(neighbor)
 					p.NumNodes--
@@ -286,35 +407,6 @@ func (p *PlannerBase) nextHaltonPoint(width, height int) geom.Coord {
 	return geom.Coord{X: x, Y: y}
 }
 
-func (p *PlannerBase) lineIntersectsObstacle(p1 geom.Coord, p2 geom.Coord, minObstacleColor uint8) bool {
-	dx := p2.X - p1.X
-	dy := p2.Y - p1.Y
-
-	m := 20000.0 // a big number for a vertical slope
-
-	if dx != 0 {
-		m = dy / dx
-	}
-
-	b := -m*p1.X + p1.Y
-
-	minX := math.Min(p1.X, p2.X)
-	maxX := math.Max(p1.X, p2.X)
-	for ix := minX; ix <= maxX; ix++ {
-		y := m*ix + b
-		if p.obstacleImage.GrayAt(int(ix), int(y)).Y > minObstacleColor {
-			return true
-		}
-	}
-
-	minY := math.Min(p1.Y, p2.Y)
-	maxY := math.Max(p1.Y, p2.Y)
-	for iY := minY; iY <= maxY; iY++ {
-		x := (iY - b) / m
-		if p.obstacleImage.GrayAt(int(x), int(iY)).Y > minObstacleColor {
-			return true
-		}
-	}
-
-	return false
+func (p *PlannerBase) lineIntersectsObstacle(p1 geom.Coord, p2 geom.Coord) bool {
+	return !p.obstacleMap.SegmentIsFree(p1, p2)
 }
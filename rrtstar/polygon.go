@@ -0,0 +1,176 @@
+package rrtstar
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/skelterjohn/geom"
+)
+
+// Polygon is an obstacle outline. The last vertex joins back up to the first and the outline
+// shouldn't cross itself
+type Polygon []*geom.Coord
+
+// Bounds returns the smallest rectangle holding every vertex
+func (p Polygon) Bounds() geom.Rect {
+	bounds := geom.Rect{
+		Min: geom.Coord{X: math.Inf(1), Y: math.Inf(1)},
+		Max: geom.Coord{X: math.Inf(-1), Y: math.Inf(-1)}}
+	for _, vertex := range p {
+		bounds.Min.X = math.Min(bounds.Min.X, vertex.X)
+		bounds.Min.Y = math.Min(bounds.Min.Y, vertex.Y)
+		bounds.Max.X = math.Max(bounds.Max.X, vertex.X)
+		bounds.Max.Y = math.Max(bounds.Max.Y, vertex.Y)
+	}
+	return bounds
+}
+
+// edge returns the ith side of the polygon
+func (p Polygon) edge(i int) (geom.Coord, geom.Coord) {
+	return *p[i], *p[(i+1)%len(p)]
+}
+
+func (p Polygon) onBoundary(point geom.Coord) bool {
+	for i := range p {
+		a, b := p.edge(i)
+		if pointToSegmentDistance(point, a, b) < 1e-9 {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsPoint reports whether point is strictly inside the polygon, points on the outline don't count
+// http://geomalgorithms.com/a03-_inclusion.html
+func (p Polygon) ContainsPoint(point geom.Coord) bool {
+	if len(p) < 3 || p.onBoundary(point) {
+		return false
+	}
+
+	inside := false
+	for i := range p {
+		a, b := p.edge(i)
+		if (a.Y > point.Y) != (b.Y > point.Y) {
+			crossingX := a.X + (point.Y-a.Y)/(b.Y-a.Y)*(b.X-a.X)
+			if point.X < crossingX {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// IntersectsSegment reports whether any part of the segment is inside the polygon. Like the
+// rectangles, running along the outline or touching a corner is allowed
+func (p Polygon) IntersectsSegment(p1, p2 geom.Coord) bool {
+	if len(p) < 3 {
+		return false
+	}
+
+	// split the segment everywhere it touches the outline, then every piece is either all
+	// inside or all outside and its middle tells which
+	dx := p2.X - p1.X
+	dy := p2.Y - p1.Y
+	lengthSquared := dx*dx + dy*dy
+	cuts := []float64{0, 1}
+	for i := range p {
+		a, b := p.edge(i)
+		ex := b.X - a.X
+		ey := b.Y - a.Y
+		denominator := dx*ey - dy*ex
+		if denominator != 0 {
+			t := ((a.X-p1.X)*ey - (a.Y-p1.Y)*ex) / denominator
+			u := ((a.X-p1.X)*dy - (a.Y-p1.Y)*dx) / denominator
+			if t > 0 && t < 1 && u >= 0 && u <= 1 {
+				cuts = append(cuts, t)
+			}
+		} else if lengthSquared > 0 {
+			// parallel, so the ends of the edge are the only places it can start or stop touching
+			for _, vertex := range []geom.Coord{a, b} {
+				t := ((vertex.X-p1.X)*dx + (vertex.Y-p1.Y)*dy) / lengthSquared
+				if t > 0 && t < 1 {
+					cuts = append(cuts, t)
+				}
+			}
+		}
+	}
+	sort.Float64s(cuts)
+
+	for i := 1; i < len(cuts); i++ {
+		t := (cuts[i-1] + cuts[i]) / 2
+		if p.ContainsPoint(geom.Coord{X: p1.X + t*dx, Y: p1.Y + t*dy}) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Polygon) area() float64 {
+	area := 0.0
+	for i := range p {
+		a, b := p.edge(i)
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return math.Abs(area) / 2
+}
+
+func pointToPolygonDistance(point geom.Coord, polygon Polygon) float64 {
+	if polygon.ContainsPoint(point) {
+		return 0
+	}
+
+	distance := math.MaxFloat64
+	for i := range polygon {
+		a, b := polygon.edge(i)
+		distance = math.Min(distance, pointToSegmentDistance(point, a, b))
+	}
+	return distance
+}
+
+// segmentToPolygonDistance is zero if the segment crosses the polygon, otherwise the closest
+// approach is between an end of the segment and an edge or a vertex and the segment
+func segmentToPolygonDistance(p1, p2 geom.Coord, polygon Polygon) float64 {
+	if polygon.IntersectsSegment(p1, p2) {
+		return 0
+	}
+
+	distance := math.MaxFloat64
+	for i := range polygon {
+		a, b := polygon.edge(i)
+		distance = math.Min(distance, pointToSegmentDistance(p1, a, b))
+		distance = math.Min(distance, pointToSegmentDistance(p2, a, b))
+		distance = math.Min(distance, pointToSegmentDistance(a, p1, p2))
+	}
+	return distance
+}
+
+// randomConvexPolygon places 3 to 8 vertices around a circle that fits inside the map
+func randomConvexPolygon(width, height int) Polygon {
+	maxRadius := math.Max(math.Min(float64(width), float64(height))/6, 5)
+	radius := 5 + rand.Float64()*(maxRadius-5)
+	center := geom.Coord{
+		X: radius + rand.Float64()*(float64(width)-2*radius),
+		Y: radius + rand.Float64()*(float64(height)-2*radius)}
+
+	angles := make([]float64, 3+rand.Intn(6))
+	for i := range angles {
+		angles[i] = rand.Float64() * 2 * math.Pi
+	}
+	sort.Float64s(angles)
+
+	polygon := make(Polygon, len(angles))
+	for i, angle := range angles {
+		polygon[i] = &geom.Coord{X: center.X + radius*math.Cos(angle), Y: center.Y + radius*math.Sin(angle)}
+	}
+	return polygon
+}
+
+// polygonOutlines converts polygons into the vertex lists the viewshed takes
+func polygonOutlines(polygons []Polygon) [][]*geom.Coord {
+	outlines := make([][]*geom.Coord, len(polygons))
+	for i, polygon := range polygons {
+		outlines[i] = polygon
+	}
+	return outlines
+}
@@ -0,0 +1,101 @@
+package rrtstar
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brychanrobot/go-rrt-star/viewshed"
+	"github.com/skelterjohn/geom"
+)
+
+// notchedPolygon is a U open at the top, with a notch from x 20 to 30 coming down to y 20
+func notchedPolygon() Polygon {
+	var polygon Polygon
+	for _, vertex := range []geom.Coord{{X: 10, Y: 10}, {X: 40, Y: 10}, {X: 40, Y: 40}, {X: 30, Y: 40},
+		{X: 30, Y: 20}, {X: 20, Y: 20}, {X: 20, Y: 40}, {X: 10, Y: 40}} {
+		vertex := vertex
+		polygon = append(polygon, &vertex)
+	}
+	return polygon
+}
+
+func TestPolygonContainsPoint(t *testing.T) {
+	polygon := notchedPolygon()
+	for _, test := range []struct {
+		name   string
+		point  geom.Coord
+		inside bool
+	}{
+		{"in an arm", geom.Coord{X: 15, Y: 30}, true},
+		{"in the base", geom.Coord{X: 25, Y: 15}, true},
+		{"in the notch", geom.Coord{X: 25, Y: 30}, false},
+		{"on an edge", geom.Coord{X: 10, Y: 25}, false},
+		{"on a vertex", geom.Coord{X: 30, Y: 20}, false},
+		{"outside", geom.Coord{X: 50, Y: 25}, false},
+	} {
+		if inside := polygon.ContainsPoint(test.point); inside != test.inside {
+			t.Errorf("%s: %v should be inside: %v", test.name, test.point, test.inside)
+		}
+	}
+}
+
+func TestPolygonIntersectsSegment(t *testing.T) {
+	polygon := notchedPolygon()
+	for _, test := range []struct {
+		name       string
+		p1, p2     geom.Coord
+		intersects bool
+	}{
+		{"into the notch", geom.Coord{X: 25, Y: 45}, geom.Coord{X: 25, Y: 25}, false},
+		{"through the bottom of the notch", geom.Coord{X: 25, Y: 45}, geom.Coord{X: 25, Y: 15}, true},
+		{"across both arms", geom.Coord{X: 0, Y: 30}, geom.Coord{X: 50, Y: 30}, true},
+		{"across the mouth of the notch", geom.Coord{X: 20, Y: 40}, geom.Coord{X: 30, Y: 40}, false},
+		{"along an edge", geom.Coord{X: 0, Y: 10}, geom.Coord{X: 50, Y: 10}, false},
+		{"grazing a corner", geom.Coord{X: 45, Y: 35}, geom.Coord{X: 35, Y: 45}, false},
+		{"cutting a corner", geom.Coord{X: 45, Y: 33}, geom.Coord{X: 33, Y: 45}, true},
+		{"beside", geom.Coord{X: 0, Y: 0}, geom.Coord{X: 0, Y: 50}, false},
+	} {
+		if intersects := polygon.IntersectsSegment(test.p1, test.p2); intersects != test.intersects {
+			t.Errorf("%s: the segment from %v to %v should intersect: %v", test.name, test.p1, test.p2, test.intersects)
+		}
+		if intersects := polygon.IntersectsSegment(test.p2, test.p1); intersects != test.intersects {
+			t.Errorf("%s reversed: the segment from %v to %v should intersect: %v", test.name, test.p2, test.p1, test.intersects)
+		}
+	}
+
+	obstacleMap := NewVectorObstacleMap(100, 100, nil, []Polygon{polygon}, nil)
+	inflated := obstacleMap.Inflate(3)
+	for _, test := range []struct {
+		obstacleMap ObstacleMap
+		point       geom.Coord
+		blocked     bool
+	}{
+		{obstacleMap, geom.Coord{X: 25, Y: 22}, false},
+		{inflated, geom.Coord{X: 25, Y: 22}, true},
+		{inflated, geom.Coord{X: 25, Y: 30}, false},
+		{inflated, geom.Coord{X: 42, Y: 25}, true},
+	} {
+		if blocked := test.obstacleMap.PointIntersects(test.point); blocked != test.blocked {
+			t.Errorf("%v should be blocked: %v", test.point, test.blocked)
+		}
+	}
+	if !inflated.SegmentIsFree(geom.Coord{X: 25, Y: 45}, geom.Coord{X: 25, Y: 30}) ||
+		inflated.SegmentIsFree(geom.Coord{X: 25, Y: 45}, geom.Coord{X: 25, Y: 22}) {
+		t.Error("the inflated notch is the wrong depth")
+	}
+}
+
+func TestPolygonsCastShadows(t *testing.T) {
+	// a square ten wide straight ahead of the center hides a cone out to the edge of the map,
+	// less the triangle between the center and its near face
+	square := Polygon{{X: 60, Y: 45}, {X: 70, Y: 45}, {X: 70, Y: 55}, {X: 60, Y: 55}}
+	shed := &viewshed.Viewshed{}
+	shed.LoadMap(100, 100, 0, nil, polygonOutlines([]Polygon{square}), nil, nil)
+	shed.UpdateCenterLocation(50, 50)
+	shed.Sweep()
+
+	hidden := 50*50*0.5 - 10*10*0.5
+	if area := viewshed.Area2DPolygon(shed.ViewablePolygon); math.Abs(area-(100*100-hidden)) > 1e-6 {
+		t.Errorf("%f can be seen, expected %f", area, 100*100-hidden)
+	}
+}
@@ -0,0 +1,107 @@
+package rrtstar
+
+import (
+	"math/rand"
+
+	"github.com/skelterjohn/geom"
+)
+
+func (p *PlannerBase) isEdgeFree(p1, p2 geom.Coord) bool {
+	return p.obstacleMap.SegmentIsFree(p1, p2)
+}
+
+// ShortcutPath greedily connects each point to the farthest later point it can see
+func (p *PlannerBase) ShortcutPath(path []*geom.Coord) []*geom.Coord {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if len(path) < 3 {
+		return append([]*geom.Coord{}, path...)
+	}
+
+	shortcut := []*geom.Coord{path[0]}
+	for i := 0; i < len(path)-1; {
+		next := i + 1
+		for j := len(path) - 1; j > i+1; j-- {
+			if p.isEdgeFree(*path[i], *path[j]) {
+				next = j
+				break
+			}
+		}
+		shortcut = append(shortcut, path[next])
+		i = next
+	}
+
+	return shortcut
+}
+
+// RandomShortcutPath tries to join random pairs of points on the path, dropping everything between them
+func (p *PlannerBase) RandomShortcutPath(path []*geom.Coord, iterations int) []*geom.Coord {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	shortcut := append([]*geom.Coord{}, path...)
+	for n := 0; n < iterations && len(shortcut) > 2; n++ {
+		i := rand.Intn(len(shortcut))
+		j := rand.Intn(len(shortcut))
+		if i > j {
+			i, j = j, i
+		}
+
+		if j-i > 1 && p.isEdgeFree(*shortcut[i], *shortcut[j]) {
+			shortcut = append(shortcut[:i+1], shortcut[j:]...)
+		}
+	}
+
+	return shortcut
+}
+
+func catmullRom(p0, p1, p2, p3 *geom.Coord, t float64) *geom.Coord {
+	t2 := t * t
+	t3 := t2 * t
+	x := 0.5 * (2*p1.X + (-p0.X+p2.X)*t + (2*p0.X-5*p1.X+4*p2.X-p3.X)*t2 + (-p0.X+3*p1.X-3*p2.X+p3.X)*t3)
+	y := 0.5 * (2*p1.Y + (-p0.Y+p2.Y)*t + (2*p0.Y-5*p1.Y+4*p2.Y-p3.Y)*t2 + (-p0.Y+3*p1.Y-3*p2.Y+p3.Y)*t3)
+	return &geom.Coord{X: x, Y: y}
+}
+
+// SmoothPath fits a catmull-rom spline through the path with samplesPerSegment points per edge.
+// Any piece of the spline that would hit an obstacle is replaced by the original straight edge
+func (p *PlannerBase) SmoothPath(path []*geom.Coord, samplesPerSegment int) []*geom.Coord {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if len(path) < 3 || samplesPerSegment < 2 {
+		return append([]*geom.Coord{}, path...)
+	}
+
+	smooth := []*geom.Coord{path[0]}
+	for i := 0; i < len(path)-1; i++ {
+		p0 := path[i]
+		if i > 0 {
+			p0 = path[i-1]
+		}
+		p1 := path[i]
+		p2 := path[i+1]
+		p3 := path[i+1]
+		if i+2 < len(path) {
+			p3 = path[i+2]
+		}
+
+		piece := make([]*geom.Coord, 0, samplesPerSegment)
+		previous := p1
+		isFree := true
+		for s := 1; s < samplesPerSegment && isFree; s++ {
+			point := catmullRom(p0, p1, p2, p3, float64(s)/float64(samplesPerSegment))
+			isFree = p.isEdgeFree(*previous, *point)
+			piece = append(piece, point)
+			previous = point
+		}
+
+		if isFree && p.isEdgeFree(*previous, *p2) {
+			smooth = append(smooth, piece...)
+		}
+		smooth = append(smooth, p2)
+	}
+
+	return smooth
+}
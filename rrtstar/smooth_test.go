@@ -0,0 +1,76 @@
+package rrtstar
+
+import (
+	"image"
+	"testing"
+
+	"github.com/skelterjohn/geom"
+)
+
+func pathLength(path []*geom.Coord) float64 {
+	length := 0.0
+	for i := 1; i < len(path); i++ {
+		length += euclideanDistance(path[i-1], path[i])
+	}
+	return length
+}
+
+func TestShortcutAndSmoothPathsStayFree(t *testing.T) {
+	// a wall from x 95 to 105 with a gap above it
+	img := image.NewGray(image.Rect(0, 0, 200, 200))
+	for y := 40; y < 200; y++ {
+		for x := 95; x < 105; x++ {
+			img.Pix[img.PixOffset(x, y)] = 255
+		}
+	}
+	config := DefaultPlannerConfig(200, 200)
+	config.CostFunction = EuclideanCost{}
+	rrt, err := NewRrtStar(NewRasterObstacleMap(img), nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a path through the gap over the wall that turns hard beside it, so a spline would swing into it
+	var path []*geom.Coord
+	for _, point := range []geom.Coord{{X: 20, Y: 100}, {X: 60, Y: 80}, {X: 94, Y: 60}, {X: 94, Y: 30},
+		{X: 106, Y: 30}, {X: 106, Y: 60}, {X: 140, Y: 80}, {X: 180, Y: 100}} {
+		point := point
+		path = append(path, &point)
+	}
+	for i := 1; i < len(path); i++ {
+		if !rrt.isEdgeFree(*path[i-1], *path[i]) {
+			t.Fatalf("the test path is blocked from %v to %v", *path[i-1], *path[i])
+		}
+	}
+
+	for _, test := range []struct {
+		name    string
+		result  []*geom.Coord
+		shorter bool
+	}{
+		{"shortcut", rrt.ShortcutPath(path), true},
+		{"random shortcut", rrt.RandomShortcutPath(path, 200), true},
+		{"smooth", rrt.SmoothPath(path, 8), false},
+	} {
+		if len(test.result) < 2 || *test.result[0] != *path[0] || *test.result[len(test.result)-1] != *path[len(path)-1] {
+			t.Errorf("%s: the path no longer runs from %v to %v", test.name, *path[0], *path[len(path)-1])
+			continue
+		}
+		for i := 1; i < len(test.result); i++ {
+			if !rrt.isEdgeFree(*test.result[i-1], *test.result[i]) {
+				t.Errorf("%s: blocked from %v to %v", test.name, *test.result[i-1], *test.result[i])
+			}
+		}
+		if test.shorter && pathLength(test.result) >= pathLength(path) {
+			t.Errorf("%s: %f long, no shorter than the original %f", test.name, pathLength(test.result), pathLength(path))
+		}
+	}
+
+	// the first point can't see past the wall, so the shortcut has to go through the gap
+	if shortcut := rrt.ShortcutPath(path); len(shortcut) < 3 {
+		t.Errorf("the shortcut went straight through the wall: %v", shortcut)
+	}
+	if smooth := rrt.SmoothPath(path, 8); len(smooth) <= len(path) {
+		t.Errorf("smoothing didn't add any points: %d before and %d after", len(path), len(smooth))
+	}
+}
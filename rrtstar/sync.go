@@ -0,0 +1,235 @@
+package rrtstar
+
+import (
+	"github.com/skelterjohn/geom"
+)
+
+// Anything that changes a planner's tree holds its lock for writing and the getters hold it for
+// reading, so a planner can be read from other goroutines while it samples. The getters hand out
+// copies, which stay as they are once the lock is let go, and the walks visit the tree under the lock
+
+// copyTree copies the tree under root so it can be walked while the planner carries on changing it
+func copyTree(root *Node) *Node {
+	if root == nil {
+		return nil
+	}
+
+	var copyNode func(node, parent *Node) *Node
+	copyNode = func(node, parent *Node) *Node {
+		copied := *node
+		copied.parent = parent
+		copied.Children = make([]*Node, len(node.Children))
+		for i, child := range node.Children {
+			copied.Children[i] = copyNode(child, &copied)
+		}
+		return &copied
+	}
+	return copyNode(root, nil)
+}
+
+// walkEdges calls visit with every edge in the tree under root, parents before their children
+func walkEdges(root *Node, visit func(parent, child *Node)) {
+	if root == nil {
+		return
+	}
+	for _, child := range root.Children {
+		visit(root, child)
+		walkEdges(child, visit)
+	}
+}
+
+// Sample performs one iteration of rrt*
+func (r *RrtStar) Sample() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sample()
+}
+
+// Sample performs one iteration of bidirectional rrt*, alternating which tree grows
+func (b *BiRrtStar) Sample() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.sample()
+}
+
+// Sample performs one iteration of dubins rrt*
+func (d *DubinsRrtStar) Sample() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.sample()
+}
+
+// Sample performs one iteration of coverage rrt*
+func (c *CoverageRrtStar) Sample() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.sample()
+}
+
+// Sample performs one iteration of fmt*
+func (f *FmtStar) Sample() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.sample()
+}
+
+// MoveStartPoint takes the root to the moved start, rerooting at the nearest node first so no node
+// is added
+func (p *PlannerBase) MoveStartPoint(dx, dy float64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.moveStartPoint(dx, dy)
+}
+
+// MoveStartPoint takes the root of the start tree to the moved start and checks the bridges from it
+func (b *BiRrtStar) MoveStartPoint(dx, dy float64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.moveStartPoint(dx, dy)
+}
+
+// MoveStartPoint takes the root to the moved start, keeping its open set in step
+func (f *FmtStar) MoveStartPoint(dx, dy float64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.moveStartPoint(dx, dy)
+}
+
+// MoveStartPoint takes the root to the moved start along curves
+func (d *DubinsRrtStar) MoveStartPoint(dx, dy float64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.moveStartPoint(dx, dy)
+}
+
+// MoveStartPoint takes the root to the moved start and works out the whole tree's coverage again
+func (c *CoverageRrtStar) MoveStartPoint(dx, dy float64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.moveStartPoint(dx, dy)
+}
+
+// MoveEndPoint moves the goal node with the end point and joins it to the best neighbor that can get
+// to it, growing the tree toward it when none can
+func (p *PlannerBase) MoveEndPoint(dx, dy float64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.moveEndPoint(dx, dy)
+}
+
+// MoveEndPoint moves the root of the goal tree in place
+func (b *BiRrtStar) MoveEndPoint(dx, dy float64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.moveEndPoint(dx, dy)
+}
+
+// MoveEndPoint moves the goal node and joins it to the neighbor with the cheapest curve to it,
+// growing the tree toward it when none can
+func (d *DubinsRrtStar) MoveEndPoint(dx, dy float64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.moveEndPoint(dx, dy)
+}
+
+// MoveEndPoint moves the goal node and joins it to the neighbor that leaves the least unseen on the
+// way to it, growing the tree toward it when none can
+func (c *CoverageRrtStar) MoveEndPoint(dx, dy float64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.moveEndPoint(dx, dy)
+}
+
+// MoveEndPoint moves the goal node and joins it back onto the tree without growing toward it
+func (f *FmtStar) MoveEndPoint(dx, dy float64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.moveEndPoint(dx, dy)
+}
+
+// GetBestPathCost returns the cumulative cost of the best path, or +Inf if no path has been found
+func (p *PlannerBase) GetBestPathCost() float64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.bestPathCost()
+}
+
+// GetBestPathCost returns the cost of the cheapest bridge between the trees, or +Inf if they haven't met
+func (b *BiRrtStar) GetBestPathCost() float64 {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.bestPathCost()
+}
+
+// GetBestPathCost returns the cost of the best path, or +Inf if there isn't one yet
+func (d *DubinsRrtStar) GetBestPathCost() float64 {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.bestPathCost()
+}
+
+// GetBestPathAndCost returns a copy of the best path and its cost, taken together
+func (p *PlannerBase) GetBestPathAndCost() ([]*geom.Coord, float64) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return copyPath(p.BestPath), p.bestPathCost()
+}
+
+// GetBestPathAndCost returns a copy of the best path and its cost, taken together
+func (b *BiRrtStar) GetBestPathAndCost() ([]*geom.Coord, float64) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return copyPath(b.BestPath), b.bestPathCost()
+}
+
+// GetBestPathAndCost returns a copy of the best path and its cost, taken together
+func (d *DubinsRrtStar) GetBestPathAndCost() ([]*geom.Coord, float64) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return copyPath(d.BestPath), d.bestPathCost()
+}
+
+// GetBestPathCoverage returns the percentage of the open map seen from the points of the best path.
+// It sweeps every point, so it's meant for reporting rather than every frame
+func (p *PlannerBase) GetBestPathCoverage() float64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.bestPathCoverage()
+}
+
+// GetBestPathCoverage returns the percentage of the open map seen along the best path, which the
+// end node already carries
+func (c *CoverageRrtStar) GetBestPathCoverage() float64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.bestPathCoverage()
+}
+
+// GetIsGoalReachable reports whether the goal node is joined to the tree. It goes false while a
+// moved goal has nothing in reach that can get to it
+func (p *PlannerBase) GetIsGoalReachable() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.isGoalReachable()
+}
+
+// GetIsGoalReachable reports whether the trees have met
+func (b *BiRrtStar) GetIsGoalReachable() bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.isGoalReachable()
+}
+
+// GetGoalRoot returns a copy of the tree grown from the goal
+func (b *BiRrtStar) GetGoalRoot() *Node {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return copyTree(b.GoalRoot)
+}
+
+// WalkGoalTree calls visit with every edge in the tree grown from the goal, like WalkTree
+func (b *BiRrtStar) WalkGoalTree(visit func(parent, child *Node)) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	walkEdges(b.GoalRoot, visit)
+}
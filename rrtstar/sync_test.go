@@ -0,0 +1,87 @@
+package rrtstar
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/skelterjohn/geom"
+)
+
+// run with -race, which is what this is for
+func TestPlannersCanBeReadWhilePlanning(t *testing.T) {
+	rects := []*geom.Rect{{Min: geom.Coord{X: 95, Y: 40}, Max: geom.Coord{X: 105, Y: 190}}}
+	obstacles := func() ObstacleMap { return NewVectorObstacleMap(200, 200, rects, nil, nil) }
+
+	unseenConfig := dynamicTestConfig()
+	unseenConfig.CostFunction = nil
+	// without a field every cost is swept, through the viewshed and cache the readers share
+	unseenConfig.VisibilitySpacing = 0
+	rrt, err := NewRrtStar(obstacles(), rects, unseenConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bi, err := NewBiRrtStar(obstacles(), rects, dynamicTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		planner Planner
+		base    *PlannerBase
+	}{{"rrt*", rrt, &rrt.PlannerBase}, {"bi", bi, &bi.PlannerBase}} {
+		updates := Plan(context.Background(), test.planner, PlanBudget{Iterations: 300})
+
+		cost, _ := test.base.costFunction.(*UnseenAreaCost)
+		done := make(chan struct{})
+		var readers sync.WaitGroup
+		for r := 0; r < 2; r++ {
+			readers.Add(1)
+			go func(r int) {
+				defer readers.Done()
+				for i := 0; ; i++ {
+					select {
+					case <-done:
+						return
+					default:
+					}
+
+					// a walk that ran alongside a change could find an edge half made
+					whole := func(parent, child *Node) {
+						if child.parent != parent {
+							t.Errorf("%s: %v is a child of %v but its parent is something else", test.name, child.Coord, parent.Coord)
+						}
+					}
+					test.planner.WalkTree(whole)
+					if bi, ok := test.planner.(*BiRrtStar); ok {
+						bi.WalkGoalTree(whole)
+					}
+					walkEdges(test.planner.GetRoot(), whole)
+
+					path := test.planner.GetBestPath()
+					test.planner.ShortcutPath(path)
+					test.planner.SmoothPath(path, 4)
+					test.planner.GetBestPathCost()
+					if cost != nil {
+						point := geom.Coord{X: float64(10 + i%80), Y: float64(10 + r*100)}
+						cost.PointCost(&point)
+						cost.EdgeCost(&point, &geom.Coord{X: 60, Y: 70})
+					}
+				}
+			}(r)
+		}
+
+		test.planner.MoveStartPoint(1, 1)
+		if err := test.planner.AddObstacle(&geom.Rect{Min: geom.Coord{X: 50, Y: 150}, Max: geom.Coord{X: 60, Y: 160}}); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		test.planner.MoveEndPoint(-1, 1)
+
+		for range updates {
+		}
+		close(done)
+		readers.Wait()
+		checkPathIsFree(t, test.name, test.planner.GetBestPath(), obstacles())
+	}
+}
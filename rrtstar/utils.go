@@ -4,7 +4,9 @@ import (
 	"image"
 	"math"
 	"math/rand"
+	"os"
 
+	"github.com/disintegration/imaging"
 	"github.com/harrydb/go/img/grayscale"
 	"github.com/llgcode/draw2d/draw2dimg"
 	"github.com/llgcode/draw2d/draw2dkit"
@@ -14,23 +16,19 @@ import (
 
 func inflateRectangle(r *geom.Rect, amount float64) {
 	offset := geom.Coord{X: amount, Y: amount}
-	r.Min.Minus(offset)
-	r.Max.Plus(offset)
+	r.Min = r.Min.Minus(offset)
+	r.Max = r.Max.Plus(offset)
 }
 
 func rectangleContainsPoint(rect geom.Rect, point geom.Coord) bool {
 	return (point.X > rect.Min.X && point.Y > rect.Min.Y) && (point.X < rect.Max.X && point.Y < rect.Max.Y)
 }
 
-func pointIntersectsObstacle(point geom.Coord, obstacles *image.Gray, minObstacleColor uint8) bool {
-	return obstacles.GrayAt(int(point.X), int(point.Y)).Y > minObstacleColor
-}
-
-func randomOpenAreaPoint(obstacles *image.Gray, width int, height int) *geom.Coord {
+func randomOpenAreaPoint(obstacleMap ObstacleMap, width int, height int) *geom.Coord {
 	var point geom.Coord
 	for true {
 		point = randomPoint(width, height)
-		if !pointIntersectsObstacle(point, obstacles, 200) {
+		if !obstacleMap.PointIntersects(point) {
 			break
 		}
 	}
@@ -94,10 +92,56 @@ func GenerateObstacles(width int, height int, count int) ([]*geom.Rect, *image.G
 		obstacles = append(obstacles, &rect)
 	}
 
-	return obstacles, generateObstacleImage(width, height, obstacles)
+	return obstacles, generateObstacleImage(width, height, obstacles, nil, nil)
 }
 
-func generateObstacleImage(width int, height int, obstacles []*geom.Rect) *image.Gray {
+// GeneratePolygonObstacles is GenerateObstacles with convex polygons in place of the rectangles
+func GeneratePolygonObstacles(width int, height int, count int) ([]Polygon, *image.Gray) {
+	var obstacles []Polygon
+	var bounds []*geom.Rect
+	for x := 0; x < count; x++ {
+		// keep trying until we get a polygon that isn't a sliver and doesn't come near any others
+		var polygon Polygon
+		var polygonBounds geom.Rect
+		for true {
+			polygon = randomConvexPolygon(width, height)
+			polygonBounds = polygon.Bounds()
+			if polygon.area() > 0.25*polygonBounds.Width()*polygonBounds.Height() && !hasIntersection(&polygonBounds, bounds) {
+				break
+			}
+		}
+
+		obstacles = append(obstacles, polygon)
+		bounds = append(bounds, &polygonBounds)
+	}
+
+	return obstacles, generateObstacleImage(width, height, nil, obstacles, nil)
+}
+
+// GenerateCircleObstacles is GenerateObstacles with circles in place of the rectangles
+func GenerateCircleObstacles(width int, height int, count int) ([]*Circle, *image.Gray) {
+	var obstacles []*Circle
+	var bounds []*geom.Rect
+	for x := 0; x < count; x++ {
+		// keep trying until we get a circle that doesn't come near any others
+		var circle *Circle
+		var circleBounds geom.Rect
+		for true {
+			circle = randomCircle(width, height)
+			circleBounds = circle.Bounds()
+			if !hasIntersection(&circleBounds, bounds) {
+				break
+			}
+		}
+
+		obstacles = append(obstacles, circle)
+		bounds = append(bounds, &circleBounds)
+	}
+
+	return obstacles, generateObstacleImage(width, height, nil, nil, obstacles)
+}
+
+func generateObstacleImage(width int, height int, obstacles []*geom.Rect, polygons []Polygon, circles []*Circle) *image.Gray {
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
 	gc := draw2dimg.NewGraphicContext(img)
 
@@ -106,7 +150,18 @@ func generateObstacleImage(width int, height int, obstacles []*geom.Rect) *image
 
 	for _, obstacle := range obstacles {
 		draw2dkit.Rectangle(gc, float64(obstacle.Min.X), float64(obstacle.Min.Y), float64(obstacle.Max.X), float64(obstacle.Max.Y))
-		inflateRectangle(obstacle, -5)
+	}
+
+	for _, polygon := range polygons {
+		gc.MoveTo(polygon[0].X, polygon[0].Y)
+		for _, vertex := range polygon[1:] {
+			gc.LineTo(vertex.X, vertex.Y)
+		}
+		gc.Close()
+	}
+
+	for _, circle := range circles {
+		draw2dkit.Circle(gc, circle.X, circle.Y, circle.Radius)
 	}
 
 	gc.Fill()
@@ -115,3 +170,21 @@ func generateObstacleImage(width int, height int, obstacles []*geom.Rect) *image
 
 	return gray
 }
+
+// LoadObstacleImage reads a map image where dark pixels are obstacles and
+// returns it inverted so obstacles are bright, matching GenerateObstacles
+func LoadObstacleImage(filename string) (*image.Gray, error) {
+	reader, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	inverted := imaging.Invert(img)
+	return grayscale.Convert(inverted, grayscale.ToGrayLuma709), nil
+}
@@ -0,0 +1,341 @@
+package rrtstar
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/brychanrobot/go-rrt-star/viewshed"
+	"github.com/skelterjohn/geom"
+)
+
+// VisibilityField is the unseen area precomputed at the middle of every cell of a grid over a map,
+// so planners can look it up instead of sweeping a viewshed for every point. Anywhere else is
+// interpolated from the four nearest grid points
+type VisibilityField struct {
+	// Hash identifies the map and the settings the field was computed with
+	Hash    string
+	Spacing float64
+	Columns int
+	Rows    int
+	// Unseen holds the unseen area of each grid point, a row at a time
+	Unseen []float64
+
+	// minUnseen is the lowest of Unseen, which nothing interpolated between them can go under
+	minUnseen float64
+}
+
+// MapHash identifies a map by its size and obstacles, so anything computed from it can be reused
+func MapHash(width, height int, obstacleRects []*geom.Rect, obstaclePolygons []Polygon, obstacleCircles []*Circle) string {
+	hash := sha256.New()
+	write := func(values ...float64) {
+		binary.Write(hash, binary.LittleEndian, values)
+	}
+
+	write(float64(width), float64(height), float64(len(obstacleRects)))
+	for _, rect := range obstacleRects {
+		write(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Max.Y)
+	}
+	write(float64(len(obstaclePolygons)))
+	for _, polygon := range obstaclePolygons {
+		write(float64(len(polygon)))
+		for _, vertex := range polygon {
+			write(vertex.X, vertex.Y)
+		}
+	}
+	write(float64(len(obstacleCircles)))
+	for _, circle := range obstacleCircles {
+		write(circle.X, circle.Y, circle.Radius)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// visibilityFieldHash adds what the field was computed with to the hash of its map
+func visibilityFieldHash(mapHash string, sensor viewshed.Sensor, spacing float64) string {
+	settings := sha256.Sum256([]byte(fmt.Sprint(spacing, sensor.MaxRange, sensor.FieldOfView, sensor.Heading)))
+	return mapHash + "-" + hex.EncodeToString(settings[:8])
+}
+
+// NewVisibilityField sweeps a viewshed with sensor from every grid point spacing apart over the map
+func NewVisibilityField(width, height int, obstacleRects []*geom.Rect, obstaclePolygons []Polygon, obstacleCircles []*Circle, sensor viewshed.Sensor, spacing float64) (*VisibilityField, error) {
+	if spacing <= 0 {
+		return nil, fmt.Errorf("visibility grid spacing must be positive, got %f", spacing)
+	}
+
+	field := &VisibilityField{
+		Hash:    visibilityFieldHash(MapHash(width, height, obstacleRects, obstaclePolygons, obstacleCircles), sensor, spacing),
+		Spacing: spacing,
+		Columns: int(math.Ceil(float64(width) / spacing)),
+		Rows:    int(math.Ceil(float64(height) / spacing))}
+
+	// the points sit in the middle of their cells so none of them land on the edge of the map
+	obstacles := NewVectorObstacleMap(width, height, obstacleRects, obstaclePolygons, obstacleCircles)
+	known := make([]bool, field.Columns*field.Rows)
+	var centers []geom.Coord
+	var indices []int
+	for row := 0; row < field.Rows; row++ {
+		for col := 0; col < field.Columns; col++ {
+			center := geom.Coord{X: (float64(col) + 0.5) * spacing, Y: (float64(row) + 0.5) * spacing}
+			if !obstacles.PointIntersects(center) {
+				centers = append(centers, center)
+				indices = append(indices, row*field.Columns+col)
+			}
+		}
+	}
+
+	var shed viewshed.Viewshed
+	shed.Sensor = sensor
+	shed.LoadMap(float64(width), float64(height), 0, obstacleRects, polygonOutlines(obstaclePolygons), circleBlocks(obstacleCircles), nil)
+
+	mapArea := float64(width * height)
+	obstacleArea := obstaclesArea(obstacleRects, obstaclePolygons, obstacleCircles)
+	field.Unseen = make([]float64, field.Columns*field.Rows)
+	for i, viewArea := range shed.SweepAreas(centers, 0) {
+		field.Unseen[indices[i]] = unseenFraction(viewArea, mapArea, obstacleArea)
+		known[indices[i]] = true
+	}
+	field.fillObstacles(known)
+	field.findMinUnseen()
+
+	return field, nil
+}
+
+// updated returns a copy of the field for a map that only differs from the one it was computed for
+// inside changed. kept are the obstacles the two maps share. Only the grid points that can see into
+// changed are swept again, found by sweeping from around its edge with the obstacles that were kept.
+// A grid point that only sees into changed through a gap narrower than those sweeps are apart can be
+// missed, but then it only sees a sliver of the change. The rest of the points only move with how much
+// of the map is taken up by obstacles
+func (f *VisibilityField) updated(width, height int, obstacleRects []*geom.Rect, obstaclePolygons []Polygon, obstacleCircles []*Circle,
+	keptRects []*geom.Rect, keptPolygons []Polygon, keptCircles []*Circle, changed geom.Rect, previousObstacleArea float64, sensor viewshed.Sensor) *VisibilityField {
+	field := &VisibilityField{
+		Hash:    visibilityFieldHash(MapHash(width, height, obstacleRects, obstaclePolygons, obstacleCircles), sensor, f.Spacing),
+		Spacing: f.Spacing,
+		Columns: f.Columns,
+		Rows:    f.Rows,
+		Unseen:  make([]float64, len(f.Unseen))}
+
+	// sweeping from right on an obstacle's edge is unreliable, so the sweeps are from a little way out
+	margin := f.Spacing / 4
+	inflateRectangle(&changed, margin)
+	changed.Min.X, changed.Min.Y = math.Max(changed.Min.X, margin), math.Max(changed.Min.Y, margin)
+	changed.Max.X, changed.Max.Y = math.Min(changed.Max.X, float64(width)-margin), math.Min(changed.Max.Y, float64(height)-margin)
+
+	affected := make([]bool, len(f.Unseen))
+	mark := func(polygon []*geom.Coord) {
+		viewshed.FillCells(polygon, f.Spacing, f.Columns, f.Rows, func(row, first, last int) {
+			for col := first; col <= last; col++ {
+				affected[row*f.Columns+col] = true
+			}
+		})
+	}
+	mark([]*geom.Coord{&changed.Min, {X: changed.Max.X, Y: changed.Min.Y}, &changed.Max, {X: changed.Min.X, Y: changed.Max.Y}})
+
+	kept := NewVectorObstacleMap(width, height, keptRects, keptPolygons, keptCircles)
+	var around []geom.Coord
+	perimeter := 2 * (changed.Width() + changed.Height())
+	steps := int(math.Ceil(perimeter / (f.Spacing / 2)))
+	for step := 0; step < steps; step++ {
+		point := pointAroundRect(changed, perimeter*float64(step)/float64(steps))
+		if !kept.PointIntersects(point) {
+			around = append(around, point)
+		}
+	}
+	var keptShed viewshed.Viewshed
+	keptShed.Sensor = sensor
+	keptShed.LoadMap(float64(width), float64(height), 0, keptRects, polygonOutlines(keptPolygons), circleBlocks(keptCircles), nil)
+	for _, polygon := range keptShed.SweepPolygons(around, 0) {
+		mark(polygon)
+	}
+
+	obstacles := NewVectorObstacleMap(width, height, obstacleRects, obstaclePolygons, obstacleCircles)
+	mapArea := float64(width * height)
+	obstacleArea := obstaclesArea(obstacleRects, obstaclePolygons, obstacleCircles)
+	known := make([]bool, len(f.Unseen))
+	var centers []geom.Coord
+	var indices []int
+	for i := range f.Unseen {
+		center := geom.Coord{X: (float64(i%f.Columns) + 0.5) * f.Spacing, Y: (float64(i/f.Columns) + 0.5) * f.Spacing}
+		switch {
+		case obstacles.PointIntersects(center):
+		case affected[i]:
+			centers = append(centers, center)
+			indices = append(indices, i)
+		default:
+			viewArea := mapArea - previousObstacleArea - f.Unseen[i]*(mapArea+previousObstacleArea)
+			field.Unseen[i] = unseenFraction(viewArea, mapArea, obstacleArea)
+			known[i] = true
+		}
+	}
+
+	var shed viewshed.Viewshed
+	shed.Sensor = sensor
+	shed.LoadMap(float64(width), float64(height), 0, obstacleRects, polygonOutlines(obstaclePolygons), circleBlocks(obstacleCircles), nil)
+	for i, viewArea := range shed.SweepAreas(centers, 0) {
+		field.Unseen[indices[i]] = unseenFraction(viewArea, mapArea, obstacleArea)
+		known[indices[i]] = true
+	}
+	field.fillObstacles(known)
+	field.findMinUnseen()
+
+	return field
+}
+
+// pointAroundRect returns the point distance along the edge of rect, going round from its Min corner
+func pointAroundRect(rect geom.Rect, distance float64) geom.Coord {
+	width, height := rect.Width(), rect.Height()
+	switch {
+	case distance < width:
+		return geom.Coord{X: rect.Min.X + distance, Y: rect.Min.Y}
+	case distance < width+height:
+		return geom.Coord{X: rect.Max.X, Y: rect.Min.Y + distance - width}
+	case distance < 2*width+height:
+		return geom.Coord{X: rect.Max.X - (distance - width - height), Y: rect.Max.Y}
+	}
+	return geom.Coord{X: rect.Min.X, Y: rect.Max.Y - (distance - 2*width - height)}
+}
+
+func (f *VisibilityField) findMinUnseen() {
+	f.minUnseen = math.Inf(1)
+	for _, unseen := range f.Unseen {
+		f.minUnseen = math.Min(f.minUnseen, unseen)
+	}
+}
+
+// fillObstacles gives the grid points inside obstacles the average of their neighbors, working in
+// from the edges a ring at a time, so points just outside an obstacle don't blend with its inside
+func (f *VisibilityField) fillObstacles(known []bool) {
+	for {
+		var filled []int
+		for i := range f.Unseen {
+			if known[i] {
+				continue
+			}
+
+			row, col := i/f.Columns, i%f.Columns
+			sum, count := 0.0, 0
+			for _, neighbor := range [][2]int{{row - 1, col}, {row + 1, col}, {row, col - 1}, {row, col + 1}} {
+				if neighbor[0] >= 0 && neighbor[0] < f.Rows && neighbor[1] >= 0 && neighbor[1] < f.Columns && known[neighbor[0]*f.Columns+neighbor[1]] {
+					sum += f.Unseen[neighbor[0]*f.Columns+neighbor[1]]
+					count++
+				}
+			}
+			if count > 0 {
+				f.Unseen[i] = sum / float64(count)
+				filled = append(filled, i)
+			}
+		}
+
+		if len(filled) == 0 {
+			return
+		}
+		for _, i := range filled {
+			known[i] = true
+		}
+	}
+}
+
+// UnseenArea interpolates the unseen area at point from the grid points around it. Points past
+// the outermost grid points take the value at the edge of the grid
+func (f *VisibilityField) UnseenArea(point *geom.Coord) float64 {
+	x := math.Max(0, math.Min(point.X/f.Spacing-0.5, float64(f.Columns-1)))
+	y := math.Max(0, math.Min(point.Y/f.Spacing-0.5, float64(f.Rows-1)))
+
+	col, row := int(x), int(y)
+	nextCol, nextRow := col, row
+	if col+1 < f.Columns {
+		nextCol = col + 1
+	}
+	if row+1 < f.Rows {
+		nextRow = row + 1
+	}
+	fx, fy := x-float64(col), y-float64(row)
+
+	at := func(row, col int) float64 {
+		return f.Unseen[row*f.Columns+col]
+	}
+	top := at(row, col)*(1-fx) + at(row, nextCol)*fx
+	bottom := at(nextRow, col)*(1-fx) + at(nextRow, nextCol)*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// Save writes the field to filename
+func (f *VisibilityField) Save(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return gob.NewEncoder(file).Encode(f)
+}
+
+// LoadVisibilityField reads a field written by Save
+func LoadVisibilityField(filename string) (*VisibilityField, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	field := &VisibilityField{}
+	if err := gob.NewDecoder(file).Decode(field); err != nil {
+		return nil, err
+	}
+	if len(field.Unseen) != field.Columns*field.Rows {
+		return nil, fmt.Errorf("%s has %d values for a %dx%d grid", filename, len(field.Unseen), field.Columns, field.Rows)
+	}
+	field.findMinUnseen()
+	return field, nil
+}
+
+var (
+	sharedFieldsLock sync.Mutex
+	sharedFields     = make(map[string]*VisibilityField)
+)
+
+// SharedVisibilityField returns the field for a map, only computing it the first time any planner
+// or waldo asks for it. When cacheDir is set the field is also saved there named by its hash, so
+// later runs on the same map can load it instead
+func SharedVisibilityField(width, height int, obstacleRects []*geom.Rect, obstaclePolygons []Polygon, obstacleCircles []*Circle, sensor viewshed.Sensor, spacing float64, cacheDir string) (*VisibilityField, error) {
+	hash := visibilityFieldHash(MapHash(width, height, obstacleRects, obstaclePolygons, obstacleCircles), sensor, spacing)
+
+	// waldos replan on their own goroutines, so they may all ask at once
+	sharedFieldsLock.Lock()
+	defer sharedFieldsLock.Unlock()
+
+	if field, ok := sharedFields[hash]; ok {
+		return field, nil
+	}
+
+	var filename string
+	if cacheDir != "" {
+		filename = filepath.Join(cacheDir, hash+".vis")
+		if field, err := LoadVisibilityField(filename); err == nil && field.Hash == hash {
+			sharedFields[hash] = field
+			return field, nil
+		}
+	}
+
+	field, err := NewVisibilityField(width, height, obstacleRects, obstaclePolygons, obstacleCircles, sensor, spacing)
+	if err != nil {
+		return nil, err
+	}
+	sharedFields[hash] = field
+
+	if filename != "" {
+		// the field still works without the cache, it just has to be computed again next time
+		if err := field.Save(filename); err != nil {
+			log.Println(err)
+		}
+	}
+	return field, nil
+}
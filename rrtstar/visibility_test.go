@@ -0,0 +1,111 @@
+package rrtstar
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/brychanrobot/go-rrt-star/viewshed"
+	"github.com/skelterjohn/geom"
+)
+
+func TestVisibilityFieldInterpolates(t *testing.T) {
+	// grid points at x 5, 15 and 25 and y 5 and 15
+	field := &VisibilityField{Spacing: 10, Columns: 3, Rows: 2, Unseen: []float64{
+		0.1, 0.2, 0.4,
+		0.3, 0.6, 0.8}}
+
+	for _, test := range []struct {
+		name   string
+		point  geom.Coord
+		unseen float64
+	}{
+		{"on a grid point", geom.Coord{X: 15, Y: 15}, 0.6},
+		{"between two in a row", geom.Coord{X: 20, Y: 5}, 0.3},
+		{"between two in a column", geom.Coord{X: 25, Y: 10}, 0.6},
+		{"between four", geom.Coord{X: 10, Y: 10}, 0.3},
+		{"a quarter of the way", geom.Coord{X: 7.5, Y: 7.5}, 0.1*0.5625 + 0.2*0.1875 + 0.3*0.1875 + 0.6*0.0625},
+		{"off the first corner", geom.Coord{X: 0, Y: 0}, 0.1},
+		{"past the last column", geom.Coord{X: 29, Y: 15}, 0.8},
+		{"off the map", geom.Coord{X: -50, Y: 10}, 0.2},
+	} {
+		if unseen := field.UnseenArea(&test.point); math.Abs(unseen-test.unseen) > 1e-12 {
+			t.Errorf("%s: %f unseen at %v, expected %f", test.name, unseen, test.point, test.unseen)
+		}
+	}
+}
+
+func TestVisibilityFieldSavesAndLoads(t *testing.T) {
+	rects := []*geom.Rect{{Min: geom.Coord{X: 20, Y: 20}, Max: geom.Coord{X: 40, Y: 30}}}
+	field, err := NewVisibilityField(60, 50, rects, nil, nil, viewshed.Sensor{}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if field.Columns != 12 || field.Rows != 10 || len(field.Unseen) != 120 {
+		t.Fatalf("a %dx%d grid with %d values", field.Columns, field.Rows, len(field.Unseen))
+	}
+
+	filename := filepath.Join(t.TempDir(), "field.vis")
+	if err := field.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadVisibilityField(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Hash != field.Hash || loaded.Spacing != field.Spacing || loaded.Columns != field.Columns || loaded.Rows != field.Rows {
+		t.Errorf("loaded %+v", loaded)
+	}
+	for i := range field.Unseen {
+		if loaded.Unseen[i] != field.Unseen[i] {
+			t.Fatalf("grid point %d went from %f to %f", i, field.Unseen[i], loaded.Unseen[i])
+		}
+	}
+	if loaded.minUnseen != field.minUnseen {
+		t.Errorf("the lowest unseen area went from %f to %f", field.minUnseen, loaded.minUnseen)
+	}
+
+	field.Unseen = field.Unseen[1:]
+	if err := field.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadVisibilityField(filename); err == nil {
+		t.Error("loading a field with a value missing didn't fail")
+	}
+	if _, err := NewVisibilityField(60, 50, rects, nil, nil, viewshed.Sensor{}, 0); err == nil {
+		t.Error("a grid with no spacing didn't fail")
+	}
+}
+
+func TestSharedVisibilityFieldChecksTheMapHash(t *testing.T) {
+	rects := []*geom.Rect{{Min: geom.Coord{X: 20, Y: 20}, Max: geom.Coord{X: 40, Y: 30}}}
+	moved := []*geom.Rect{{Min: geom.Coord{X: 21, Y: 20}, Max: geom.Coord{X: 41, Y: 30}}}
+	if MapHash(70, 50, rects, nil, nil) == MapHash(70, 50, moved, nil, nil) {
+		t.Error("moving an obstacle didn't change the map hash")
+	}
+	if MapHash(70, 50, rects, nil, nil) != MapHash(70, 50, []*geom.Rect{{Min: rects[0].Min, Max: rects[0].Max}}, nil, nil) {
+		t.Error("the same map hashed differently")
+	}
+
+	// a file in the cache under the map's name that was computed for another map is ignored
+	cacheDir := t.TempDir()
+	hash := visibilityFieldHash(MapHash(70, 50, rects, nil, nil), viewshed.Sensor{}, 5)
+	stale, err := NewVisibilityField(70, 50, moved, nil, nil, viewshed.Sensor{}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stale.Save(filepath.Join(cacheDir, hash+".vis")); err != nil {
+		t.Fatal(err)
+	}
+
+	field, err := SharedVisibilityField(70, 50, rects, nil, nil, viewshed.Sensor{}, 5, cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if field.Hash != hash {
+		t.Error("the field saved for another map was used")
+	}
+	if again, _ := SharedVisibilityField(70, 50, rects, nil, nil, viewshed.Sensor{}, 5, cacheDir); again != field {
+		t.Error("the field was computed again")
+	}
+}
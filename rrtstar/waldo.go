@@ -1,8 +1,9 @@
 package rrtstar
 
 import (
-	"image"
+	"log"
 	"math"
+	"sync"
 
 	"github.com/skelterjohn/geom"
 )
@@ -17,28 +18,36 @@ const (
 const maxTravel = 2
 
 type Waldo struct {
-	geom.Coord
+	position      geom.Coord
 	movementType  MovementType
-	obstacleImage *image.Gray
+	obstacleMap   ObstacleMap
 	obstacleRects []*geom.Rect
 	mapBounds     geom.Rect
 	Importance    uint32
 	heading       float64
 	//rrtStar       *RrtStar
-	CurrentPath     []*geom.Coord
-	Replanning      bool
-	CurrentWaypoint *geom.Coord
+	currentPath     []*geom.Coord
+	replanning      bool
+	currentWaypoint *geom.Coord
+	// lock guards the position and path, which the planning goroutine and anything drawing the waldo
+	// read while it moves
+	lock sync.RWMutex
+	// Config is what the waldo plans its paths with. Matching the main planner's visibility
+	// settings lets them share a visibility field
+	Config PlannerConfig
 }
 
-func NewWaldo(movementType MovementType, importance uint32, obstacleImage *image.Gray) *Waldo {
-	mapBounds := obstacleImage.Bounds()
+func NewWaldo(movementType MovementType, importance uint32, obstacleMap ObstacleMap) *Waldo {
 	waldo := &Waldo{
-		movementType:  movementType,
-		Importance:    importance,
-		obstacleImage: obstacleImage,
-		mapBounds:     geom.Rect{Min: geom.Coord{X: float64(mapBounds.Min.X), Y: float64(mapBounds.Min.Y)}, Max: geom.Coord{X: float64(mapBounds.Max.X), Y: float64(mapBounds.Max.Y)}}}
+		movementType: movementType,
+		Importance:   importance,
+		obstacleMap:  obstacleMap,
+		mapBounds:    obstacleMap.Bounds()}
 
-	waldo.Coord = *randomOpenAreaPoint(obstacleImage, int(waldo.mapBounds.Width()), int(waldo.mapBounds.Height()))
+	waldo.Config = DefaultPlannerConfig(int(waldo.mapBounds.Width()), int(waldo.mapBounds.Height()))
+	waldo.Config.MaxSegment = 30
+
+	waldo.position = *randomOpenAreaPoint(obstacleMap, int(waldo.mapBounds.Width()), int(waldo.mapBounds.Height()))
 	//log.Println(waldo.Point)
 	return waldo
 }
@@ -59,7 +68,7 @@ func (w *Waldo) walkRandomly() {
 
 		newPoint = w.Point.Add(image.Pt(int(dx), int(dy)))
 
-		isInObstacle = !rectangleContainsPoint(w.mapBounds, newPoint) || pointIntersectsObstacle(newPoint, w.obstacleImage, 20)
+		isInObstacle = !rectangleContainsPoint(w.mapBounds, newPoint) || w.obstacleMap.PointIntersects(newPoint)
 	}
 
 	//log.Println(newPoint)
@@ -68,35 +77,85 @@ func (w *Waldo) walkRandomly() {
 }
 */
 
+// GetPosition returns where the waldo is
+func (w *Waldo) GetPosition() geom.Coord {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.position
+}
+
+// GetCurrentPath returns a copy of what's left of the waldo's path, ending with the next waypoint
+func (w *Waldo) GetCurrentPath() []*geom.Coord {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return copyPath(w.currentPath)
+}
+
+// GetCurrentWaypoint returns the point the waldo is heading for, or nil if it hasn't set off
+func (w *Waldo) GetCurrentWaypoint() *geom.Coord {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.currentWaypoint == nil {
+		return nil
+	}
+	waypoint := *w.currentWaypoint
+	return &waypoint
+}
+
+// GetIsReplanning reports whether the waldo is waiting on a new path
+func (w *Waldo) GetIsReplanning() bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.replanning
+}
+
+// replan finds a path from start on its own goroutine and hands it to the waldo
+func (w *Waldo) replan(start geom.Coord) {
+	config := w.Config
+	config.StartPoint = &start
+	rrtStar, err := NewRrtStar(w.obstacleMap, w.obstacleRects, config)
+
+	var path []*geom.Coord
+	if err != nil {
+		log.Println(err)
+	} else {
+		for len(rrtStar.BestPath) == 0 {
+			rrtStar.Sample()
+		}
+		path = copyPath(rrtStar.BestPath[:len(rrtStar.BestPath)-1])
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.currentPath = path
+	w.replanning = false
+}
+
 func (w *Waldo) followRrtPath() {
-	if !w.Replanning {
-		if len(w.CurrentPath) == 0 {
-			w.Replanning = true
-			go func() {
-				rrtStar := NewRrtStar(w.obstacleImage, w.obstacleRects, 30, int(w.mapBounds.Width()), int(w.mapBounds.Height()), &w.Coord, nil)
-				for len(rrtStar.BestPath) == 0 {
-					rrtStar.Sample()
-				}
-				w.CurrentPath = rrtStar.BestPath[:len(rrtStar.BestPath)-1]
-				w.Replanning = false
-			}()
+	if !w.replanning {
+		if len(w.currentPath) == 0 {
+			w.replanning = true
+			go w.replan(w.position)
 			return
 		}
 
-		w.CurrentWaypoint = w.CurrentPath[len(w.CurrentPath)-1]
-		if euclideanDistance(&w.Coord, w.CurrentWaypoint) <= maxTravel {
-			w.CurrentPath = w.CurrentPath[:len(w.CurrentPath)-1]
-			w.Coord = *w.CurrentWaypoint
+		w.currentWaypoint = w.currentPath[len(w.currentPath)-1]
+		if euclideanDistance(&w.position, w.currentWaypoint) <= maxTravel {
+			w.currentPath = w.currentPath[:len(w.currentPath)-1]
+			w.position = *w.currentWaypoint
 		} else {
-			angle := angleBetweenFloatPoints(w.X, w.Y, float64(w.CurrentWaypoint.X), float64(w.CurrentWaypoint.Y))
-			w.Coord.X += maxTravel * math.Cos(angle)
-			w.Coord.Y += maxTravel * math.Sin(angle)
+			angle := angleBetweenFloatPoints(w.position.X, w.position.Y, float64(w.currentWaypoint.X), float64(w.currentWaypoint.Y))
+			w.position.X += maxTravel * math.Cos(angle)
+			w.position.Y += maxTravel * math.Sin(angle)
 		}
 	}
 
 }
 
 func (w *Waldo) MoveWaldo() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
 	switch w.movementType {
 	case RandomWalk:
 		//w.walkRandomly()
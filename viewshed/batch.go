@@ -0,0 +1,82 @@
+package viewshed
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/skelterjohn/geom"
+)
+
+// Clone copies the loaded map, sensor and center into a viewshed that can sweep without touching this one
+func (v *Viewshed) Clone() *Viewshed {
+	clone := &Viewshed{Sensor: v.Sensor}
+	for _, segment := range v.Segments {
+		clone.addSegment(segment.P1.X, segment.P1.Y, segment.P2.X, segment.P2.Y)
+		// crossings are only read once the map is loaded, so the copies can share them
+		clone.Segments[len(clone.Segments)-1].crossings = segment.crossings
+	}
+	for _, block := range v.Blocks {
+		copied := NewBlock(block.x, block.y, block.r)
+		copied.crossings = block.crossings
+		clone.Blocks = append(clone.Blocks, copied)
+		clone.endpoints = append(clone.endpoints, copied.tangents[0], copied.tangents[1])
+	}
+	clone.UpdateCenterLocation(v.Center.X, v.Center.Y)
+	return clone
+}
+
+// sweepEach sweeps from every center on a pool of workers, each with its own copy of the map, and
+// hands each worker's polygon to use before that worker moves on. workers <= 0 uses every cpu
+func (v *Viewshed) sweepEach(centers []geom.Coord, workers int, use func(i int, polygon []*geom.Coord)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(centers) {
+		workers = len(centers)
+	}
+
+	jobs := make(chan int, len(centers))
+	for i := range centers {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		worker := v.Clone()
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				worker.UpdateCenterLocation(centers[i].X, centers[i].Y)
+				worker.Sweep()
+				use(i, worker.ViewablePolygon)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// SweepPolygons computes the visibility polygon from each of the centers in parallel. The polygons
+// are in the same order as the centers and are copies, so they stay put after later sweeps
+func (v *Viewshed) SweepPolygons(centers []geom.Coord, workers int) [][]*geom.Coord {
+	polygons := make([][]*geom.Coord, len(centers))
+	v.sweepEach(centers, workers, func(i int, polygon []*geom.Coord) {
+		// the points belong to the worker's map and tangents move with the center
+		copied := make([]*geom.Coord, len(polygon))
+		for j, point := range polygon {
+			copied[j] = &geom.Coord{X: point.X, Y: point.Y}
+		}
+		polygons[i] = copied
+	})
+	return polygons
+}
+
+// SweepAreas computes the area that can be seen from each of the centers in parallel
+func (v *Viewshed) SweepAreas(centers []geom.Coord, workers int) []float64 {
+	areas := make([]float64, len(centers))
+	v.sweepEach(centers, workers, func(i int, polygon []*geom.Coord) {
+		areas[i] = Area2DPolygon(polygon)
+	})
+	return areas
+}
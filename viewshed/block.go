@@ -1,7 +1,74 @@
 package viewshed
 
+import (
+	"math"
+
+	"github.com/skelterjohn/geom"
+)
+
+// Block is a round obstacle. The sweep sees it as the two points where lines of sight from the
+// center just graze it
 type Block struct {
-	x float64
-	y float64
-	r float64
+	x        float64
+	y        float64
+	r        float64
+	tangents [2]*EndPoint
+	hidden   bool // the center is inside the block, so it has no tangents
+	// crossings are where other obstacles cross the edge of the block
+	crossings []*geom.Coord
+}
+
+// NewBlock creates a block centered at x, y with radius r
+func NewBlock(x, y, r float64) *Block {
+	block := &Block{x: x, y: y, r: r}
+	for i := range block.tangents {
+		block.tangents[i] = &EndPoint{Coord: &geom.Coord{}, block: block}
+	}
+	return block
+}
+
+// updateTangents moves the tangent endpoints to where lines from center touch the block
+func (b *Block) updateTangents(center geom.Coord) {
+	dx := b.x - center.X
+	dy := b.y - center.Y
+	distance := math.Sqrt(dx*dx + dy*dy)
+
+	b.hidden = distance <= b.r
+	if b.hidden {
+		return
+	}
+
+	toBlock := math.Atan2(dy, dx)
+	spread := math.Asin(b.r / distance)
+	length := math.Sqrt(distance*distance - b.r*b.r)
+	for i, side := range []float64{-1, 1} {
+		angle := toBlock + side*spread
+		tangent := b.tangents[i]
+		tangent.X = center.X + length*math.Cos(angle)
+		tangent.Y = center.Y + length*math.Sin(angle)
+		tangent.angle = math.Atan2(tangent.Y-center.Y, tangent.X-center.X)
+		// the block starts to hide things at the clockwise tangent and stops at the other
+		tangent.begin = side < 0
+	}
+}
+
+// rayIntersection returns where a ray from center at angle first hits the block and the square of its distance
+func (b *Block) rayIntersection(center *geom.Coord, angle float64) (*geom.Coord, float64, bool) {
+	dirX := math.Cos(angle)
+	dirY := math.Sin(angle)
+	fx := center.X - b.x
+	fy := center.Y - b.y
+
+	along := fx*dirX + fy*dirY
+	discriminant := along*along - (fx*fx + fy*fy - b.r*b.r)
+	if discriminant < 0 {
+		return nil, 0, false
+	}
+
+	t := -along - math.Sqrt(discriminant)
+	if t <= 0 {
+		return nil, 0, false
+	}
+
+	return &geom.Coord{X: center.X + t*dirX, Y: center.Y + t*dirY}, t * t, true
 }
@@ -0,0 +1,439 @@
+package viewshed
+
+import (
+	"math"
+	"sort"
+
+	"github.com/skelterjohn/geom"
+)
+
+// weldTolerance is how close two points have to be to count as the same vertex when polygons are combined
+const weldTolerance = 1e-7
+
+// Polygon is an outline with holes cut out of it. Outlines go the same way round as a ViewablePolygon,
+// so Area2DPolygon is positive for them, and holes go the other way
+type Polygon struct {
+	Outline []*geom.Coord
+	Holes   [][]*geom.Coord
+}
+
+// MultiPolygon is a region made up of polygons that don't overlap
+type MultiPolygon []Polygon
+
+// NewMultiPolygon copies a polygon such as a ViewablePolygon into a region that can be combined with others
+func NewMultiPolygon(points []*geom.Coord) MultiPolygon {
+	outline := make([]*geom.Coord, 0, len(points))
+	for i, point := range points {
+		if *point != *points[(i+1)%len(points)] {
+			outline = append(outline, &geom.Coord{X: point.X, Y: point.Y})
+		}
+	}
+	if len(outline) < 3 || Area2DPolygon(outline) == 0 {
+		return nil
+	}
+	if Area2DPolygon(outline) < 0 {
+		reverse(outline)
+	}
+	return MultiPolygon{Polygon{Outline: outline}}
+}
+
+// Region returns what the last sweep could see as a region that can be combined with other viewsheds
+func (v *Viewshed) Region() MultiPolygon {
+	return NewMultiPolygon(v.ViewablePolygon)
+}
+
+// Union returns the region inside either a or b, such as what two observers can see between them
+func Union(a, b MultiPolygon) MultiPolygon {
+	return combine(a, b, func(fromA bool, where place) (bool, bool) {
+		return where == outside || where == sharedSame, false
+	})
+}
+
+// Intersection returns the region inside both a and b, such as what two observers can both see
+func Intersection(a, b MultiPolygon) MultiPolygon {
+	return combine(a, b, func(fromA bool, where place) (bool, bool) {
+		return where == inside || where == sharedSame, false
+	})
+}
+
+// Difference returns the region inside a but not b, such as what one observer sees and the other doesn't
+func Difference(a, b MultiPolygon) MultiPolygon {
+	return combine(a, b, func(fromA bool, where place) (bool, bool) {
+		if fromA {
+			return where == outside || where == sharedOpposite, false
+		}
+		// the part of b's boundary inside a is the edge of a hole, so it goes the other way round
+		return where == inside, true
+	})
+}
+
+// place is where a piece of one region's boundary lies relative to the other region
+type place int
+
+const (
+	outside place = iota
+	inside
+	sharedSame     // on the other boundary going the same way, so both regions are on the same side
+	sharedOpposite // on the other boundary going the other way, so the regions are on opposite sides
+)
+
+// piece is a part of an edge between the places it gets cut. Interiors are always to its left
+type piece struct {
+	from geom.Coord
+	to   geom.Coord
+}
+
+// combine cuts the boundaries of a and b wherever they meet and keeps the pieces that keep says are
+// on the boundary of the result, turning round the ones it asks to, then joins them back into rings.
+// Vertices are welded before anything is cut, so points that are the same to within rounding are
+// cut at and joined up as one
+func combine(a, b MultiPolygon, keep func(fromA bool, where place) (kept bool, reversed bool)) MultiPolygon {
+	welded := welder{}
+	edges := append(a.edges(true, welded), b.edges(false, welded)...)
+	findCuts(edges)
+
+	var aPieces, bPieces []piece
+	for _, edge := range edges {
+		if edge.fromA {
+			aPieces = append(aPieces, edge.pieces(welded)...)
+		} else {
+			bPieces = append(bPieces, edge.pieces(welded)...)
+		}
+	}
+
+	var kept []piece
+	classify := func(pieces []piece, fromA bool, otherPieces []piece) {
+		onOther := make(map[piece]bool, len(otherPieces))
+		for _, p := range otherPieces {
+			onOther[p] = true
+		}
+
+		for _, p := range pieces {
+			var where place
+			switch {
+			case onOther[p]:
+				where = sharedSame
+			case onOther[piece{from: p.to, to: p.from}]:
+				where = sharedOpposite
+			case piecesContain(otherPieces, geom.Coord{X: (p.from.X + p.to.X) / 2, Y: (p.from.Y + p.to.Y) / 2}):
+				where = inside
+			default:
+				where = outside
+			}
+			// a shared piece is on both boundaries, but only needs to be kept once
+			if !fromA && (where == sharedSame || where == sharedOpposite) {
+				continue
+			}
+			if keepIt, reversed := keep(fromA, where); keepIt {
+				if reversed {
+					p = piece{from: p.to, to: p.from}
+				}
+				kept = append(kept, p)
+			}
+		}
+	}
+	classify(aPieces, true, bPieces)
+	classify(bPieces, false, aPieces)
+
+	return assemble(kept)
+}
+
+// boundaryEdge is an edge of an outline or hole along with the places it's cut
+type boundaryEdge struct {
+	p1    geom.Coord
+	p2    geom.Coord
+	fromA bool
+	cuts  []edgeCut
+}
+
+// edgeCut is where an edge is cut, t of the way along it
+type edgeCut struct {
+	t     float64
+	point geom.Coord
+}
+
+func (e *boundaryEdge) bounds() geom.Rect {
+	return geom.Rect{
+		Min: geom.Coord{X: math.Min(e.p1.X, e.p2.X), Y: math.Min(e.p1.Y, e.p2.Y)},
+		Max: geom.Coord{X: math.Max(e.p1.X, e.p2.X), Y: math.Max(e.p1.Y, e.p2.Y)}}
+}
+
+// rings returns every outline and hole of the region
+func (m MultiPolygon) rings() [][]*geom.Coord {
+	var rings [][]*geom.Coord
+	for _, polygon := range m {
+		rings = append(rings, polygon.Outline)
+		rings = append(rings, polygon.Holes...)
+	}
+	return rings
+}
+
+// edges returns the edges of every ring with their ends welded, leaving out any that weld down to nothing
+func (m MultiPolygon) edges(fromA bool, welded welder) []*boundaryEdge {
+	var edges []*boundaryEdge
+	for _, ring := range m.rings() {
+		for i, p1 := range ring {
+			from, to := welded.weld(*p1), welded.weld(*ring[(i+1)%len(ring)])
+			if from != to {
+				edges = append(edges, &boundaryEdge{p1: from, p2: to, fromA: fromA})
+			}
+		}
+	}
+	return edges
+}
+
+// piecesContain reports whether the point is inside the boundary the pieces were cut from, counting
+// how many of them a ray from it crosses. The pieces have been welded, so they agree with each other
+// where the original rings may not
+func piecesContain(pieces []piece, point geom.Coord) bool {
+	in := false
+	for _, p := range pieces {
+		if (p.from.Y > point.Y) != (p.to.Y > point.Y) && point.X < p.from.X+(point.Y-p.from.Y)*(p.to.X-p.from.X)/(p.to.Y-p.from.Y) {
+			in = !in
+		}
+	}
+	return in
+}
+
+// ringPieces returns the edges of a ring as pieces, so piecesContain can check it
+func ringPieces(ring []*geom.Coord) []piece {
+	pieces := make([]piece, len(ring))
+	for i, point := range ring {
+		pieces[i] = piece{from: *point, to: *ring[(i+1)%len(ring)]}
+	}
+	return pieces
+}
+
+// findCuts cuts edges of one region wherever an edge of the other crosses, touches or runs along
+// them. As with the obstacles' crossings, only edges whose bounds overlap are compared
+func findCuts(edges []*boundaryEdge) {
+	sort.Slice(edges, func(i, j int) bool { return edges[i].bounds().Min.X < edges[j].bounds().Min.X })
+
+	for i, a := range edges {
+		aBounds := a.bounds()
+		for _, b := range edges[i+1:] {
+			bBounds := b.bounds()
+			if bBounds.Min.X > aBounds.Max.X+weldTolerance {
+				break
+			}
+			if a.fromA == b.fromA || bBounds.Min.Y > aBounds.Max.Y+weldTolerance || aBounds.Min.Y > bBounds.Max.Y+weldTolerance {
+				continue
+			}
+			a.cut(b)
+		}
+	}
+}
+
+// cut records where a and b meet on both of them. An end of either that lies on the other cuts it at
+// exactly that end, which also covers edges running along each other, so only edges that cross
+// away from their ends need a crossing worked out
+func (e *boundaryEdge) cut(other *boundaryEdge) {
+	a, b := e, other
+	touching := b.cutAt(a.p1)
+	touching = b.cutAt(a.p2) || touching
+	touching = a.cutAt(b.p1) || touching
+	touching = a.cutAt(b.p2) || touching
+	if touching || a.p1 == b.p1 || a.p1 == b.p2 || a.p2 == b.p1 || a.p2 == b.p2 {
+		return
+	}
+
+	adx, ady := a.p2.X-a.p1.X, a.p2.Y-a.p1.Y
+	bdx, bdy := b.p2.X-b.p1.X, b.p2.Y-b.p1.Y
+	ex, ey := b.p1.X-a.p1.X, b.p1.Y-a.p1.Y
+	denominator := adx*bdy - ady*bdx
+	if denominator == 0 {
+		return
+	}
+
+	ta := (ex*bdy - ey*bdx) / denominator
+	tb := (ex*ady - ey*adx) / denominator
+	if ta <= 0 || ta >= 1 || tb <= 0 || tb >= 1 {
+		return
+	}
+	point := *interpolate(&a.p1, &a.p2, ta)
+	a.cuts = append(a.cuts, edgeCut{t: ta, point: point})
+	b.cuts = append(b.cuts, edgeCut{t: tb, point: point})
+}
+
+// cutAt cuts the edge at point if point is within weldTolerance of it between its ends, and reports
+// whether it did
+func (e *boundaryEdge) cutAt(point geom.Coord) bool {
+	dx, dy := e.p2.X-e.p1.X, e.p2.Y-e.p1.Y
+	length := math.Sqrt(dx*dx + dy*dy)
+	t := ((point.X-e.p1.X)*dx + (point.Y-e.p1.Y)*dy) / (length * length)
+	if t <= 0 || t >= 1 || point == e.p1 || point == e.p2 || math.Abs((point.X-e.p1.X)*dy-(point.Y-e.p1.Y)*dx) > weldTolerance*length {
+		return false
+	}
+	e.cuts = append(e.cuts, edgeCut{t: t, point: point})
+	return true
+}
+
+// pieces splits the edge at its cuts, dropping any piece that welds down to nothing
+func (e *boundaryEdge) pieces(welded welder) []piece {
+	sort.Slice(e.cuts, func(i, j int) bool { return e.cuts[i].t < e.cuts[j].t })
+
+	points := []geom.Coord{welded.weld(e.p1)}
+	for _, cut := range e.cuts {
+		if cut.t > 0 && cut.t < 1 {
+			points = append(points, welded.weld(cut.point))
+		}
+	}
+	points = append(points, welded.weld(e.p2))
+
+	var pieces []piece
+	for i := 1; i < len(points); i++ {
+		if points[i-1] != points[i] {
+			pieces = append(pieces, piece{from: points[i-1], to: points[i]})
+		}
+	}
+	return pieces
+}
+
+// welder merges points within weldTolerance of each other, so vertices and crossings of the two
+// regions that only differ by rounding come out as the same vertex
+type welder map[[2]int64][]geom.Coord
+
+func (w welder) weld(point geom.Coord) geom.Coord {
+	x := int64(math.Floor(point.X / weldTolerance))
+	y := int64(math.Floor(point.Y / weldTolerance))
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for _, other := range w[[2]int64{x + dx, y + dy}] {
+				if math.Abs(other.X-point.X) <= weldTolerance && math.Abs(other.Y-point.Y) <= weldTolerance {
+					return other
+				}
+			}
+		}
+	}
+	w[[2]int64{x, y}] = append(w[[2]int64{x, y}], point)
+	return point
+}
+
+// assemble joins pieces end to end into rings and sorts them into outlines and the holes inside them
+func assemble(pieces []piece) MultiPolygon {
+	outgoing := make(map[geom.Coord][]int)
+	for i, p := range pieces {
+		outgoing[p.from] = append(outgoing[p.from], i)
+	}
+
+	used := make([]bool, len(pieces))
+	var outlines, holes [][]*geom.Coord
+	var outlinePieces [][]piece
+	for start := range pieces {
+		if used[start] {
+			continue
+		}
+
+		var ring []*geom.Coord
+		var around []piece
+		for current := start; ; {
+			used[current] = true
+			ring = append(ring, &geom.Coord{X: pieces[current].from.X, Y: pieces[current].from.Y})
+			around = append(around, pieces[current])
+			if pieces[current].to == pieces[start].from {
+				break
+			}
+			// pieces that don't close up are joined straight back to the start, which can only
+			// be off by rounding, rather than losing the whole ring
+			next := nextPiece(pieces, outgoing[pieces[current].to], used, pieces[current])
+			if next < 0 {
+				around = append(around, piece{from: pieces[current].to, to: pieces[start].from})
+				break
+			}
+			current = next
+		}
+
+		// only rings with nothing inside them are dropped
+		if len(ring) < 3 {
+			continue
+		}
+		switch area := Area2DPolygon(ring); {
+		case math.Abs(area) < weldTolerance:
+		case area > 0:
+			outlines = append(outlines, ring)
+			outlinePieces = append(outlinePieces, around)
+		default:
+			holes = append(holes, ring)
+		}
+	}
+
+	region := make(MultiPolygon, len(outlines))
+	for i, outline := range outlines {
+		region[i].Outline = outline
+	}
+	// a hole belongs to the smallest outline around it. Its vertices and edges can touch the outline, so
+	// the outline is checked at a point strictly between the hole and whatever boundary is next to it
+	for _, hole := range holes {
+		point := pointBeside(hole, pieces)
+		best := -1
+		for i, outline := range outlines {
+			if piecesContain(outlinePieces[i], point) && (best < 0 || Area2DPolygon(outline) < Area2DPolygon(outlines[best])) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			region[best].Holes = append(region[best].Holes, hole)
+		}
+	}
+	return region
+}
+
+// pointBeside returns a point just to the left of the longest edge of ring, halfway to the next boundary
+// in that direction, so it's strictly inside or outside every ring the pieces make up
+func pointBeside(ring []*geom.Coord, boundary []piece) geom.Coord {
+	var from, to geom.Coord
+	length := -1.0
+	for i, point := range ring {
+		next := *ring[(i+1)%len(ring)]
+		if l := math.Hypot(next.X-point.X, next.Y-point.Y); l > length {
+			from, to, length = *point, next, l
+		}
+	}
+
+	middle := geom.Coord{X: (from.X + to.X) / 2, Y: (from.Y + to.Y) / 2}
+	normalX, normalY := -(to.Y-from.Y)/length, (to.X-from.X)/length
+	nearest := length
+	for _, p := range boundary {
+		if p.from == from && p.to == to {
+			continue
+		}
+		edgeX, edgeY := p.to.X-p.from.X, p.to.Y-p.from.Y
+		denominator := normalX*edgeY - normalY*edgeX
+		if denominator == 0 {
+			continue
+		}
+		// how far along the normal the edge is crossed, and where along the edge
+		offsetX, offsetY := p.from.X-middle.X, p.from.Y-middle.Y
+		distance := (offsetX*edgeY - offsetY*edgeX) / denominator
+		along := (offsetX*normalY - offsetY*normalX) / denominator
+		if distance > 0 && along >= 0 && along <= 1 {
+			nearest = math.Min(nearest, distance)
+		}
+	}
+	return geom.Coord{X: middle.X + normalX*nearest/2, Y: middle.Y + normalY*nearest/2}
+}
+
+// nextPiece picks the unused piece leaving where previous ends that turns furthest left. The interior is
+// on the left, so that keeps to the edge of one polygon where two of them touch at a corner
+func nextPiece(pieces []piece, candidates []int, used []bool, previous piece) int {
+	inX, inY := previous.to.X-previous.from.X, previous.to.Y-previous.from.Y
+	best := -1
+	bestTurn := math.Inf(-1)
+	for _, i := range candidates {
+		if used[i] {
+			continue
+		}
+		outX, outY := pieces[i].to.X-pieces[i].from.X, pieces[i].to.Y-pieces[i].from.Y
+		if turn := math.Atan2(inX*outY-inY*outX, inX*outX+inY*outY); turn > bestTurn {
+			best = i
+			bestTurn = turn
+		}
+	}
+	return best
+}
+
+func reverse(points []*geom.Coord) {
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+}
@@ -0,0 +1,157 @@
+package viewshed
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/skelterjohn/geom"
+)
+
+// randomViewsheds sweeps count viewsheds from random points of a random map, so they share obstacle
+// edges and corners the way viewsheds on one map do
+func randomViewsheds(random *rand.Rand, count int) []MultiPolygon {
+	const width, height = 400.0, 300.0
+
+	var rects []*geom.Rect
+	for i := 0; i < 2+random.Intn(6); i++ {
+		x, y := random.Float64()*width, random.Float64()*height
+		// whole numbers line obstacles up with each other, which is where rounding goes wrong
+		rects = append(rects, &geom.Rect{
+			Min: geom.Coord{X: math.Floor(x), Y: math.Floor(y)},
+			Max: geom.Coord{X: math.Floor(x + 10 + random.Float64()*60), Y: math.Floor(y + 10 + random.Float64()*60)}})
+	}
+	var blocks []*Block
+	for i := 0; i < random.Intn(3); i++ {
+		blocks = append(blocks, NewBlock(random.Float64()*width, random.Float64()*height, 5+random.Float64()*20))
+	}
+
+	shed := &Viewshed{}
+	if random.Intn(2) == 0 {
+		shed.Sensor.MaxRange = 50 + random.Float64()*200
+	}
+	if random.Intn(3) == 0 {
+		shed.Sensor.FieldOfView = math.Pi/4 + random.Float64()*math.Pi
+	}
+	shed.LoadMap(width, height, 0, rects, nil, blocks, nil)
+
+	var regions []MultiPolygon
+	for len(regions) < count {
+		x, y := random.Float64()*width, random.Float64()*height
+		blocked := false
+		for _, rect := range rects {
+			blocked = blocked || rect.ContainsCoord(geom.Coord{X: x, Y: y})
+		}
+		if blocked {
+			continue
+		}
+		shed.Sensor.Heading = random.Float64() * 2 * math.Pi
+		shed.UpdateCenterLocation(x, y)
+		shed.Sweep()
+		regions = append(regions, shed.Region())
+	}
+	return regions
+}
+
+func TestBooleanAreaIdentities(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 300; trial++ {
+		regions := randomViewsheds(random, 2)
+		a, b := regions[0], regions[1]
+		areaA, areaB := Area2DMultiPolygon(a), Area2DMultiPolygon(b)
+		union := Area2DMultiPolygon(Union(a, b))
+		intersection := Area2DMultiPolygon(Intersection(a, b))
+		difference := Area2DMultiPolygon(Difference(a, b))
+
+		tolerance := 1e-6 * (areaA + areaB)
+		if math.Abs(union+intersection-areaA-areaB) > tolerance {
+			t.Errorf("trial %d: area(A∪B)+area(A∩B) = %f but area(A)+area(B) = %f", trial, union+intersection, areaA+areaB)
+		}
+		if math.Abs(difference-(areaA-intersection)) > tolerance {
+			t.Errorf("trial %d: area(A\\B) = %f but area(A)-area(A∩B) = %f", trial, difference, areaA-intersection)
+		}
+	}
+}
+
+func TestBooleanAreaIdentitiesOfCombinedRegions(t *testing.T) {
+	// regions that are already unions have holes and several outlines, which single viewsheds don't
+	random := rand.New(rand.NewSource(2))
+	for trial := 0; trial < 100; trial++ {
+		regions := randomViewsheds(random, 4)
+		a, b := Union(regions[0], regions[1]), Difference(regions[2], regions[3])
+		areaA, areaB := Area2DMultiPolygon(a), Area2DMultiPolygon(b)
+		union := Area2DMultiPolygon(Union(a, b))
+		intersection := Area2DMultiPolygon(Intersection(a, b))
+		difference := Area2DMultiPolygon(Difference(a, b))
+
+		tolerance := 1e-6 * (areaA + areaB)
+		if math.Abs(union+intersection-areaA-areaB) > tolerance {
+			t.Errorf("trial %d: area(A∪B)+area(A∩B) = %f but area(A)+area(B) = %f", trial, union+intersection, areaA+areaB)
+		}
+		if math.Abs(difference-(areaA-intersection)) > tolerance {
+			t.Errorf("trial %d: area(A\\B) = %f but area(A)-area(A∩B) = %f", trial, difference, areaA-intersection)
+		}
+	}
+}
+
+func TestBooleanSharedEdges(t *testing.T) {
+	square := func(x0, y0, x1, y1 float64) MultiPolygon {
+		return NewMultiPolygon([]*geom.Coord{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}})
+	}
+	a := square(0, 0, 2, 2)
+	for _, test := range []struct {
+		name                              string
+		b                                 MultiPolygon
+		union, intersection, differenceAB float64
+	}{
+		{"overlapping", square(1, 1, 3, 3), 7, 1, 3},
+		{"sharing an edge", square(2, 0, 4, 2), 8, 0, 4},
+		{"sharing part of an edge", square(2, 1, 3, 3), 6, 0, 4},
+		{"touching at a corner", square(2, 2, 3, 3), 5, 0, 4},
+		{"inside", square(0.5, 0.5, 1.5, 1.5), 4, 1, 3},
+		{"inside along an edge", square(0, 0.5, 1, 1.5), 4, 1, 3},
+		{"the same", square(0, 0, 2, 2), 4, 4, 0},
+		{"empty", nil, 4, 0, 4},
+	} {
+		check := func(operation string, region MultiPolygon, want float64) {
+			if got := Area2DMultiPolygon(region); math.Abs(got-want) > 1e-9 {
+				t.Errorf("%s %s: area %f, want %f", test.name, operation, got, want)
+			}
+		}
+		check("union", Union(a, test.b), test.union)
+		check("intersection", Intersection(a, test.b), test.intersection)
+		check("difference", Difference(a, test.b), test.differenceAB)
+	}
+}
+
+func TestBooleanHoleTouchingItsOutline(t *testing.T) {
+	ring := func(points ...float64) []*geom.Coord {
+		var coords []*geom.Coord
+		for i := 0; i < len(points); i += 2 {
+			coords = append(coords, &geom.Coord{X: points[i], Y: points[i+1]})
+		}
+		return coords
+	}
+	// the hole's corner sits on the outline's left side, which is where both rings start
+	a := MultiPolygon{{
+		Outline: ring(0, 2, 0, 0, 4, 0, 4, 4, 0, 4),
+		Holes:   [][]*geom.Coord{ring(0, 2, 2, 3, 2, 1)}}}
+	b := NewMultiPolygon(ring(6, 0, 7, 0, 7, 1, 6, 1))
+
+	union := Union(a, b)
+	if got := Area2DMultiPolygon(union); math.Abs(got-15) > 1e-9 {
+		t.Errorf("area %f, want 15", got)
+	}
+	holes := 0
+	for _, polygon := range union {
+		holes += len(polygon.Holes)
+		for _, hole := range polygon.Holes {
+			if math.Abs(Area2DPolygon(polygon.Outline)) != 16 {
+				t.Errorf("the hole %v went to the outline %v", hole, polygon.Outline)
+			}
+		}
+	}
+	if holes != 1 {
+		t.Errorf("%d holes, want 1", holes)
+	}
+}
@@ -0,0 +1,159 @@
+package viewshed
+
+import (
+	"math"
+	"sort"
+
+	"github.com/skelterjohn/geom"
+)
+
+// crossingMargin keeps a crossing right at the end of a segment from cutting off a piece with no length
+const crossingMargin = 1e-9
+
+// findCrossings records everywhere two obstacles cross. Overlapping obstacles can swap which one is
+// in front partway along, so the sweep cuts them into pieces there that never cross each other
+func (v *Viewshed) findCrossings() {
+	for _, segment := range v.Segments {
+		segment.crossings = segment.crossings[:0]
+	}
+	for _, block := range v.Blocks {
+		block.crossings = block.crossings[:0]
+	}
+
+	// only obstacles whose bounds overlap can cross, so sort them by their left sides and each one
+	// only has to look at the ones that start before it ends
+	var obstacles []crossingBounds
+	for _, segment := range v.Segments {
+		obstacles = append(obstacles, crossingBounds{
+			Rect: geom.Rect{
+				Min: geom.Coord{X: math.Min(segment.P1.X, segment.P2.X), Y: math.Min(segment.P1.Y, segment.P2.Y)},
+				Max: geom.Coord{X: math.Max(segment.P1.X, segment.P2.X), Y: math.Max(segment.P1.Y, segment.P2.Y)}},
+			segment: segment})
+	}
+	for _, block := range v.Blocks {
+		obstacles = append(obstacles, crossingBounds{
+			Rect: geom.Rect{
+				Min: geom.Coord{X: block.x - block.r, Y: block.y - block.r},
+				Max: geom.Coord{X: block.x + block.r, Y: block.y + block.r}},
+			block: block})
+	}
+	sort.Slice(obstacles, func(i, j int) bool { return obstacles[i].Min.X < obstacles[j].Min.X })
+
+	for i, a := range obstacles {
+		for _, b := range obstacles[i+1:] {
+			if b.Min.X > a.Max.X {
+				break
+			}
+			if b.Min.Y > a.Max.Y || a.Min.Y > b.Max.Y {
+				continue
+			}
+			a.cross(b)
+		}
+	}
+
+	for _, segment := range v.Segments {
+		start := segment.P1.Coord
+		crossings := segment.crossings
+		sort.Slice(crossings, func(i, j int) bool {
+			return squareDistance(start, crossings[i]) < squareDistance(start, crossings[j])
+		})
+	}
+}
+
+// crossingBounds is a segment or block with the bounds it's checked for crossings by
+type crossingBounds struct {
+	geom.Rect
+	segment *Segment
+	block   *Block
+}
+
+// cross records where two obstacles cross
+func (a crossingBounds) cross(b crossingBounds) {
+	switch {
+	case a.segment != nil && b.segment != nil:
+		if ta, tb, ok := segmentCrossing(a.segment, b.segment); ok {
+			a.segment.addCrossing(ta)
+			b.segment.addCrossing(tb)
+		}
+	case a.block != nil && b.block != nil:
+		for _, crossing := range a.block.blockCrossings(b.block) {
+			a.block.crossings = append(a.block.crossings, crossing)
+			b.block.crossings = append(b.block.crossings, crossing)
+		}
+	default:
+		segment, block := a.segment, b.block
+		if segment == nil {
+			segment, block = b.segment, a.block
+		}
+		for _, t := range block.segmentCrossings(segment) {
+			segment.addCrossing(t)
+			block.crossings = append(block.crossings, interpolate(segment.P1.Coord, segment.P2.Coord, t))
+		}
+	}
+}
+
+// addCrossing cuts the segment at t along it, unless that's one of its ends
+func (s *Segment) addCrossing(t float64) {
+	if t > crossingMargin && t < 1-crossingMargin {
+		s.crossings = append(s.crossings, interpolate(s.P1.Coord, s.P2.Coord, t))
+	}
+}
+
+// segmentCrossing returns how far along a and b they meet, if they do
+func segmentCrossing(a, b *Segment) (float64, float64, bool) {
+	adx, ady := a.P2.X-a.P1.X, a.P2.Y-a.P1.Y
+	bdx, bdy := b.P2.X-b.P1.X, b.P2.Y-b.P1.Y
+	denominator := adx*bdy - ady*bdx
+	if denominator == 0 {
+		// parallel segments can only overlap, and then neither is in front of the other
+		return 0, 0, false
+	}
+
+	ex, ey := b.P1.X-a.P1.X, b.P1.Y-a.P1.Y
+	ta := (ex*bdy - ey*bdx) / denominator
+	tb := (ex*ady - ey*adx) / denominator
+	return ta, tb, ta >= 0 && ta <= 1 && tb >= 0 && tb <= 1
+}
+
+// segmentCrossings returns how far along s it crosses the edge of the block
+func (b *Block) segmentCrossings(s *Segment) []float64 {
+	dx := s.P2.X - s.P1.X
+	dy := s.P2.Y - s.P1.Y
+	fx := s.P1.X - b.x
+	fy := s.P1.Y - b.y
+
+	a := dx*dx + dy*dy
+	half := fx*dx + fy*dy
+	discriminant := half*half - a*(fx*fx+fy*fy-b.r*b.r)
+	if a == 0 || discriminant <= 0 {
+		return nil
+	}
+
+	var crossings []float64
+	root := math.Sqrt(discriminant)
+	for _, t := range []float64{(-half - root) / a, (-half + root) / a} {
+		if t >= 0 && t <= 1 {
+			crossings = append(crossings, t)
+		}
+	}
+	return crossings
+}
+
+// blockCrossings returns where the edges of two blocks cross
+func (b *Block) blockCrossings(other *Block) []*geom.Coord {
+	dx := other.x - b.x
+	dy := other.y - b.y
+	distance := math.Sqrt(dx*dx + dy*dy)
+	if distance >= b.r+other.r || distance <= math.Abs(b.r-other.r) {
+		return nil
+	}
+
+	// the crossings are either side of the line between the centers, the same distance along it
+	along := (distance*distance + b.r*b.r - other.r*other.r) / (2 * distance)
+	side := math.Sqrt(b.r*b.r - along*along)
+	mx := b.x + along*dx/distance
+	my := b.y + along*dy/distance
+	return []*geom.Coord{
+		&geom.Coord{X: mx - side*dy/distance, Y: my + side*dx/distance},
+		&geom.Coord{X: mx + side*dy/distance, Y: my - side*dx/distance}}
+}
@@ -7,6 +7,7 @@ type EndPoint struct {
 	*geom.Coord
 	begin     bool
 	segment   *Segment
+	block     *Block // set instead of segment for the tangent points of a block
 	angle     float64
 	visualize bool
 }
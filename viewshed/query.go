@@ -0,0 +1,64 @@
+package viewshed
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/skelterjohn/geom"
+)
+
+// IsVisible reports whether the point could be seen in the last sweep
+func (v *Viewshed) IsVisible(point geom.Coord) bool {
+	// the center is a corner of a field of view's wedge, where piecesContain can go either way
+	return point == v.Center || piecesContain(ringPieces(v.ViewablePolygon), point)
+}
+
+// VisibleFraction returns how much of the area of the polygon could be seen in the last sweep
+func (v *Viewshed) VisibleFraction(polygon []*geom.Coord) float64 {
+	region := NewMultiPolygon(polygon)
+	area := Area2DMultiPolygon(region)
+	if area == 0 {
+		return 0
+	}
+	return Area2DMultiPolygon(Intersection(v.Region(), region)) / area
+}
+
+// FillCells finds the cells of a grid of columns by rows cellSize apart whose middles are inside
+// polygon, a row at a time, and calls fill with each run of them from first to last
+func FillCells(polygon []*geom.Coord, cellSize float64, columns, rows int, fill func(row, first, last int)) {
+	var crossings []float64
+	for row := 0; row < rows; row++ {
+		y := (float64(row) + 0.5) * cellSize
+
+		crossings = crossings[:0]
+		for i, p1 := range polygon {
+			p2 := polygon[(i+1)%len(polygon)]
+			if (p1.Y <= y) != (p2.Y <= y) {
+				crossings = append(crossings, p1.X+(y-p1.Y)*(p2.X-p1.X)/(p2.Y-p1.Y))
+			}
+		}
+		sort.Float64s(crossings)
+
+		// the row is inside the polygon between every other pair of crossings
+		for i := 0; i+1 < len(crossings); i += 2 {
+			first := int(math.Max(0, math.Ceil(crossings[i]/cellSize-0.5)))
+			last := int(math.Min(float64(columns-1), math.Floor(crossings[i+1]/cellSize-0.5)))
+			if first <= last {
+				fill(row, first, last)
+			}
+		}
+	}
+}
+
+// Rasterize draws what could be seen in the last sweep as white on black, filling every pixel
+// whose middle is inside the viewable polygon
+func (v *Viewshed) Rasterize(width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	FillCells(v.ViewablePolygon, 1, width, height, func(row, first, last int) {
+		for col := first; col <= last; col++ {
+			img.Pix[row*img.Stride+col] = 255
+		}
+	})
+	return img
+}
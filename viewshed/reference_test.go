@@ -0,0 +1,162 @@
+package viewshed
+
+import (
+	"math"
+	"sort"
+
+	"github.com/skelterjohn/geom"
+)
+
+// ReferenceSweep runs referenceSweep for the tests outside the package
+func (v *Viewshed) ReferenceSweep() {
+	v.referenceSweep()
+}
+
+func referenceIsWithinRange(target float64, a float64, b float64) bool {
+	if math.Abs(a-b) > math.Pi {
+		return a >= target && b >= target || a <= target && b <= target
+	}
+	return a <= target && target <= b || b <= target && target <= a
+}
+
+func referenceIsPassThrough(point *EndPoint, segment *Segment) bool {
+	if point.X == segment.P1.X && point.Y == segment.P1.Y {
+		return point.begin == segment.P1.begin
+	}
+	if point.X == segment.P2.X && point.Y == segment.P2.Y {
+		return point.begin == segment.P2.begin
+	}
+	return false
+}
+
+// referenceSweep is the sweep from before the heap, which casts every ray against every obstacle
+func (v *Viewshed) referenceSweep() {
+	v.ViewablePolygon = v.ViewablePolygon[:0] // clear output
+	sort.Sort(ByAngleThenBegin(v.endpoints))
+
+	// the two segments meeting at a corner both have an endpoint there, but the ray only needs casting once
+	visited := make(map[geom.Coord]bool, len(v.endpoints)/2)
+	for _, e := range v.endpoints {
+		if visited[*e.Coord] || e.block != nil && e.block.hidden {
+			continue
+		}
+		visited[*e.Coord] = true
+
+		var intersectedSegments []*Segment
+		var hasPassThrough bool
+		for _, segment := range v.Segments {
+			if segment != e.segment && referenceIsWithinRange(e.angle, segment.P1.angle, segment.P2.angle) {
+				isPassThrough := referenceIsPassThrough(e, segment)
+				if !isPassThrough {
+					intersectedSegments = append(intersectedSegments, segment)
+				}
+				hasPassThrough = hasPassThrough || isPassThrough
+
+			}
+		}
+
+		// a tangent always passes through since the ray only grazes its block
+		hasPassThrough = hasPassThrough || e.block != nil
+
+		closestIntersection := e.Coord //the intersection is the point if there isn't anything else
+		closestIntersectionDist := math.MaxFloat64
+
+		for _, segment := range intersectedSegments {
+			intersection := lineIntersection(&v.Center, e.Coord, segment.P1.Coord, segment.P2.Coord)
+			dist := squareDistance(&v.Center, intersection)
+			if dist < closestIntersectionDist {
+				closestIntersection = intersection
+				closestIntersectionDist = dist
+			}
+		}
+
+		for _, block := range v.Blocks {
+			if block == e.block || block.hidden {
+				continue
+			}
+			if intersection, dist, ok := block.rayIntersection(&v.Center, e.angle); ok && dist < closestIntersectionDist {
+				closestIntersection = intersection
+				closestIntersectionDist = dist
+			}
+		}
+
+		if hasPassThrough && closestIntersectionDist > squareDistance(&v.Center, e.Coord) {
+			if e.begin {
+				v.ViewablePolygon = append(v.ViewablePolygon, closestIntersection)
+				v.ViewablePolygon = append(v.ViewablePolygon, e.Coord)
+			} else {
+				v.ViewablePolygon = append(v.ViewablePolygon, e.Coord)
+				v.ViewablePolygon = append(v.ViewablePolygon, closestIntersection)
+			}
+		} else {
+			v.ViewablePolygon = append(v.ViewablePolygon, closestIntersection)
+		}
+	}
+
+	if len(v.Blocks) > 0 {
+		v.referenceAddArcs()
+	}
+
+	if v.Sensor.IsDirectional() {
+		v.clipToFieldOfView()
+	}
+	if v.Sensor.IsRangeLimited() {
+		v.clipToRange()
+	}
+}
+
+// referenceClosestBlock returns the block a ray from the center at angle hits first, or nil if a segment is in the way
+func (v *Viewshed) referenceClosestBlock(angle float64) *Block {
+	closestDist := math.MaxFloat64
+	direction := geom.Coord{X: v.Center.X + math.Cos(angle), Y: v.Center.Y + math.Sin(angle)}
+	for _, segment := range v.Segments {
+		if referenceIsWithinRange(angle, segment.P1.angle, segment.P2.angle) {
+			intersection := lineIntersection(&v.Center, &direction, segment.P1.Coord, segment.P2.Coord)
+			closestDist = math.Min(closestDist, squareDistance(&v.Center, intersection))
+		}
+	}
+
+	var closest *Block
+	for _, block := range v.Blocks {
+		if block.hidden {
+			continue
+		}
+		if _, dist, ok := block.rayIntersection(&v.Center, angle); ok && dist < closestDist {
+			closest = block
+			closestDist = dist
+		}
+	}
+	return closest
+}
+
+// referenceAddArcs is addArcs with referenceClosestBlock, since closestBlock needs the heap's snapshots
+func (v *Viewshed) referenceAddArcs() {
+	polygon := make([]*geom.Coord, 0, len(v.ViewablePolygon))
+	for i, p1 := range v.ViewablePolygon {
+		polygon = append(polygon, p1)
+
+		p2 := v.ViewablePolygon[(i+1)%len(v.ViewablePolygon)]
+		angle1 := math.Atan2(p1.Y-v.Center.Y, p1.X-v.Center.X)
+		span := math.Atan2(p2.Y-v.Center.Y, p2.X-v.Center.X) - angle1
+		if span <= -math.Pi {
+			span += 2 * math.Pi
+		}
+		// points on the same ray can come out a hair apart either way
+		if span < 1e-9 {
+			continue
+		}
+
+		block := v.referenceClosestBlock(angle1 + span/2)
+		if block == nil {
+			continue
+		}
+
+		steps := int(math.Ceil(span / arcStep))
+		for step := 1; step < steps; step++ {
+			if point, _, ok := block.rayIntersection(&v.Center, angle1+span*float64(step)/float64(steps)); ok {
+				polygon = append(polygon, point)
+			}
+		}
+	}
+	v.ViewablePolygon = polygon
+}
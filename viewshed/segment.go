@@ -1,8 +1,12 @@
 package viewshed
 
+import "github.com/skelterjohn/geom"
+
 // Segment holds the start, end, and distance of a segment
 type Segment struct {
 	P1 *EndPoint
 	P2 *EndPoint
 	d  float64
+	// crossings are where other obstacles cross the segment, in order from P1 to P2
+	crossings []*geom.Coord
 }
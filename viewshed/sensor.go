@@ -0,0 +1,151 @@
+package viewshed
+
+import (
+	"math"
+
+	"github.com/skelterjohn/geom"
+)
+
+// Sensor limits how far and which way the viewshed can see. The zero value sees everything
+type Sensor struct {
+	MaxRange    float64 // 0 means there is no limit
+	FieldOfView float64 // radians, 0 or anything past 2pi means all the way around
+	Heading     float64 // radians, the middle of the field of view
+}
+
+// IsRangeLimited reports whether the sensor has a maximum range
+func (s Sensor) IsRangeLimited() bool {
+	return s.MaxRange > 0
+}
+
+// IsDirectional reports whether the sensor only sees part of the way around
+func (s Sensor) IsDirectional() bool {
+	return s.FieldOfView > 0 && s.FieldOfView < 2*math.Pi
+}
+
+func mod2pi(angle float64) float64 {
+	angle = math.Mod(angle, 2*math.Pi)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	return angle
+}
+
+func (v *Viewshed) angleTo(point *geom.Coord) float64 {
+	return math.Atan2(point.Y-v.Center.Y, point.X-v.Center.X)
+}
+
+// boundaryAt returns where the ray from the center at angle crosses the edge from p1 to p2
+func (v *Viewshed) boundaryAt(angle float64, p1, p2 *geom.Coord) *geom.Coord {
+	direction := geom.Coord{X: v.Center.X + math.Cos(angle), Y: v.Center.Y + math.Sin(angle)}
+	denominator := (p2.Y-p1.Y)*(direction.X-v.Center.X) - (p2.X-p1.X)*(direction.Y-v.Center.Y)
+	if math.Abs(denominator) < 1e-12 {
+		// the edge points straight at the center, so the ray runs along it
+		return p2
+	}
+	return lineIntersection(&v.Center, &direction, p1, p2)
+}
+
+// clipToFieldOfView cuts the polygon down to the wedge the sensor faces. The polygon is in order
+// of angle around the center, so that's the run of vertices between the two sides of the wedge
+func (v *Viewshed) clipToFieldOfView() {
+	n := len(v.ViewablePolygon)
+	if n < 2 {
+		return
+	}
+
+	start := v.Sensor.Heading - v.Sensor.FieldOfView/2
+	relativeAngle := func(point *geom.Coord) float64 {
+		return mod2pi(v.angleTo(point) - start)
+	}
+
+	// the edge the start of the wedge crosses is where the relative angle wraps back to 0
+	first := 0
+	biggestDrop := math.Inf(-1)
+	for i, p1 := range v.ViewablePolygon {
+		p2 := v.ViewablePolygon[(i+1)%n]
+		if drop := relativeAngle(p1) - relativeAngle(p2); drop > biggestDrop {
+			biggestDrop = drop
+			first = i
+		}
+	}
+
+	prev := v.ViewablePolygon[first]
+	next := v.ViewablePolygon[(first+1)%n]
+	polygon := []*geom.Coord{&geom.Coord{X: v.Center.X, Y: v.Center.Y}, v.boundaryAt(start, prev, next)}
+	// both sides of a wide wedge can cross the same edge, so the walk may come back around to it
+	for i := 1; i <= n+1; i++ {
+		next = v.ViewablePolygon[(first+i)%n]
+		wrapped := i > 1 && relativeAngle(next) < relativeAngle(prev)-math.Pi
+		if wrapped || relativeAngle(next) >= v.Sensor.FieldOfView {
+			break
+		}
+		polygon = append(polygon, next)
+		prev = next
+	}
+	polygon = append(polygon, v.boundaryAt(start+v.Sensor.FieldOfView, prev, next))
+
+	v.ViewablePolygon = polygon
+}
+
+// rangeArc traces the edge of the sensor's range between the angles of from and to, leaving off the end
+func (v *Viewshed) rangeArc(from, to *geom.Coord) []*geom.Coord {
+	angle := v.angleTo(from)
+	span := v.angleTo(to) - angle
+	if span <= -math.Pi {
+		span += 2 * math.Pi
+	} else if span > math.Pi {
+		span -= 2 * math.Pi
+	}
+
+	steps := int(math.Max(1, math.Ceil(span/arcStep)))
+	arc := make([]*geom.Coord, 0, steps)
+	for step := 0; step < steps; step++ {
+		stepAngle := angle + math.Max(span, 0)*float64(step)/float64(steps)
+		arc = append(arc, &geom.Coord{
+			X: v.Center.X + v.Sensor.MaxRange*math.Cos(stepAngle),
+			Y: v.Center.Y + v.Sensor.MaxRange*math.Sin(stepAngle)})
+	}
+	return arc
+}
+
+// clipToRange keeps the parts of each edge within range and swaps the rest for arcs at the range
+func (v *Viewshed) clipToRange() {
+	rangeSquared := v.Sensor.MaxRange * v.Sensor.MaxRange
+	polygon := make([]*geom.Coord, 0, len(v.ViewablePolygon))
+	for i, p1 := range v.ViewablePolygon {
+		p2 := v.ViewablePolygon[(i+1)%len(v.ViewablePolygon)]
+
+		// find where the edge crosses the circle of the range
+		dx := p2.X - p1.X
+		dy := p2.Y - p1.Y
+		fx := p1.X - v.Center.X
+		fy := p1.Y - v.Center.Y
+		a := dx*dx + dy*dy
+		b := fx*dx + fy*dy
+		c := fx*fx + fy*fy - rangeSquared
+		cuts := []float64{0}
+		if discriminant := b*b - a*c; a > 0 && discriminant > 0 {
+			root := math.Sqrt(discriminant)
+			for _, t := range []float64{(-b - root) / a, (-b + root) / a} {
+				if t > 0 && t < 1 {
+					cuts = append(cuts, t)
+				}
+			}
+		}
+		cuts = append(cuts, 1)
+
+		// each piece starts where the last one ended, so only the starts are added
+		for j := 1; j < len(cuts); j++ {
+			pieceStart := interpolate(p1, p2, cuts[j-1])
+			middle := interpolate(p1, p2, (cuts[j-1]+cuts[j])/2)
+			if squareDistance(&v.Center, middle) <= rangeSquared {
+				polygon = append(polygon, pieceStart)
+			} else {
+				polygon = append(polygon, v.rangeArc(pieceStart, interpolate(p1, p2, cuts[j]))...)
+			}
+		}
+	}
+
+	v.ViewablePolygon = polygon
+}
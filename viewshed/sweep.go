@@ -0,0 +1,371 @@
+package viewshed
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+
+	"github.com/skelterjohn/geom"
+)
+
+// occluder is a segment or block, or the piece of one between crossings, while it's open during the sweep
+type occluder struct {
+	segment *Segment
+	block   *Block
+	order   int // position in Segments or Blocks, ties go to whichever came first
+	// the occluder is open from lo to hi, or from lo around through pi to hi when it wraps
+	lo        float64
+	hi        float64
+	wraps     bool
+	heapIndex int
+}
+
+func newOccluder(segment *Segment, block *Block, order int, a1, a2 float64) *occluder {
+	o := &occluder{segment: segment, block: block, order: order, lo: math.Min(a1, a2), hi: math.Max(a1, a2), heapIndex: -1}
+	// anything spanning more than pi is taken to go the short way round, through pi
+	if o.hi-o.lo > math.Pi {
+		o.lo, o.hi, o.wraps = o.hi, o.lo, true
+	}
+	return o
+}
+
+// intervals returns the angles the occluder is open over without any wrapping
+func (o *occluder) intervals() [][2]float64 {
+	if o.wraps {
+		return [][2]float64{{-math.Pi, o.hi}, {o.lo, math.Pi}}
+	}
+	return [][2]float64{{o.lo, o.hi}}
+}
+
+// distanceAt returns the square of the distance from center to the occluder along the ray at angle
+func (o *occluder) distanceAt(center *geom.Coord, angle float64) float64 {
+	if o.block != nil {
+		if _, dist, ok := o.block.rayIntersection(center, angle); ok {
+			return dist
+		}
+		return math.Inf(1)
+	}
+	direction := geom.Coord{X: center.X + math.Cos(angle), Y: center.Y + math.Sin(angle)}
+	return squareDistance(center, lineIntersection(center, &direction, o.segment.P1.Coord, o.segment.P2.Coord))
+}
+
+// openSet is a heap of the open occluders with the closest to the center on top. Occluders are cut
+// where they cross, so the one in front stays in front for as long as they are both open
+type openSet struct {
+	center    *geom.Coord
+	angle     float64 // where the sweep is, used when two occluders only share a single angle
+	occluders []*occluder
+}
+
+func (s *openSet) Len() int { return len(s.occluders) }
+func (s *openSet) Swap(i, j int) {
+	s.occluders[i], s.occluders[j] = s.occluders[j], s.occluders[i]
+	s.occluders[i].heapIndex = i
+	s.occluders[j].heapIndex = j
+}
+
+// Less compares the occluders in the middle of the widest range of angles they are both open over,
+// away from any corner they share
+func (s *openSet) Less(i, j int) bool {
+	a := s.occluders[i]
+	b := s.occluders[j]
+
+	angle := s.angle
+	widest := -1.0
+	for _, ai := range a.intervals() {
+		for _, bi := range b.intervals() {
+			lo := math.Max(ai[0], bi[0])
+			hi := math.Min(ai[1], bi[1])
+			if hi-lo > widest {
+				widest = hi - lo
+				angle = (lo + hi) / 2
+			}
+		}
+	}
+	if widest < 0 {
+		angle = s.angle
+	}
+
+	return a.distanceAt(s.center, angle) < b.distanceAt(s.center, angle)
+}
+
+func (s *openSet) Push(x interface{}) {
+	o := x.(*occluder)
+	o.heapIndex = len(s.occluders)
+	s.occluders = append(s.occluders, o)
+}
+
+func (s *openSet) Pop() interface{} {
+	last := s.occluders[len(s.occluders)-1]
+	last.heapIndex = -1
+	s.occluders = s.occluders[:len(s.occluders)-1]
+	return last
+}
+
+func (s *openSet) top() *occluder {
+	if len(s.occluders) == 0 {
+		return nil
+	}
+	return s.occluders[0]
+}
+
+// sweepEvent opens or closes an occluder at angle
+type sweepEvent struct {
+	angle    float64
+	occluder *occluder
+}
+
+func sortEvents(events []sweepEvent) {
+	sort.Slice(events, func(i, j int) bool { return events[i].angle < events[j].angle })
+}
+
+// snapshot is the closest occluder between angle and the next snapshot
+type snapshot struct {
+	angle   float64
+	closest *occluder
+}
+
+// Sweep computes a visibility polygon and returns all of the points, clipped to what the sensor can see.
+// Occluders are opened and closed in order of angle with the closest open one on top of a heap, so
+// each ray only has to look at the few occluders in front of its endpoint
+func (v *Viewshed) Sweep() {
+	v.ViewablePolygon = v.ViewablePolygon[:0] // clear output
+	sort.Sort(ByAngleThenBegin(v.endpoints))
+
+	v.open = openSet{center: &v.Center, angle: -math.Pi, occluders: v.open.occluders[:0]}
+	var openings, closings []sweepEvent
+	addOccluder := func(o *occluder) {
+		if o.wraps {
+			// already open at -pi, it closes partway round and opens again later
+			heap.Push(&v.open, o)
+			closings = append(closings, sweepEvent{o.hi, o})
+			openings = append(openings, sweepEvent{o.lo, o})
+		} else {
+			openings = append(openings, sweepEvent{o.lo, o})
+			closings = append(closings, sweepEvent{o.hi, o})
+		}
+	}
+	for i, segment := range v.Segments {
+		// a segment pointing straight at the center never blocks a ray, the ray just runs along it
+		if segment.P1.angle == segment.P2.angle {
+			continue
+		}
+		from := segment.P1.angle
+		for _, crossing := range segment.crossings {
+			to := v.angleTo(crossing)
+			if to != from {
+				addOccluder(newOccluder(segment, nil, i, from, to))
+			}
+			from = to
+		}
+		if segment.P2.angle != from {
+			addOccluder(newOccluder(segment, nil, i, from, segment.P2.angle))
+		}
+	}
+	for i, block := range v.Blocks {
+		if block.hidden {
+			continue
+		}
+		// only crossings on the side facing the center change what is in front
+		from := block.tangents[0].angle
+		span := mod2pi(block.tangents[1].angle - from)
+		var cuts []float64
+		for _, crossing := range block.crossings {
+			facing := (crossing.X-block.x)*(crossing.X-v.Center.X)+(crossing.Y-block.y)*(crossing.Y-v.Center.Y) < 0
+			if cut := v.angleTo(crossing); facing && mod2pi(cut-from) > 0 && mod2pi(cut-from) < span {
+				cuts = append(cuts, cut)
+			}
+		}
+		sort.Slice(cuts, func(i, j int) bool { return mod2pi(cuts[i]-from) < mod2pi(cuts[j]-from) })
+		for _, to := range cuts {
+			if to != from {
+				addOccluder(newOccluder(nil, block, i, from, to))
+			}
+			from = to
+		}
+		if block.tangents[1].angle != from {
+			addOccluder(newOccluder(nil, block, i, from, block.tangents[1].angle))
+		}
+	}
+	sortEvents(openings)
+	sortEvents(closings)
+
+	v.snapshots = append(v.snapshots[:0], snapshot{angle: math.Inf(-1), closest: v.open.top()})
+
+	// the two segments meeting at a corner both have an endpoint there, but the ray only needs casting once
+	visited := make(map[geom.Coord]bool, len(v.endpoints)/2)
+	for start := 0; start < len(v.endpoints) || len(openings) > 0 || len(closings) > 0; {
+		// step to whatever happens next, an endpoint or a piece of an occluder opening or closing
+		angle := math.Inf(1)
+		if start < len(v.endpoints) {
+			angle = v.endpoints[start].angle
+		}
+		if len(openings) > 0 {
+			angle = math.Min(angle, openings[0].angle)
+		}
+		if len(closings) > 0 {
+			angle = math.Min(angle, closings[0].angle)
+		}
+		end := start
+		for end < len(v.endpoints) && v.endpoints[end].angle == angle {
+			end++
+		}
+		v.open.angle = angle
+
+		// occluders are open at both of their ends, but the ones opening here stay out of the heap
+		// until the ones closing here are gone so the heap only compares occluders that share more
+		// than a single angle. Until then the rays check them directly
+		opening := 0
+		for opening < len(openings) && openings[opening].angle <= angle {
+			opening++
+		}
+
+		for _, e := range v.endpoints[start:end] {
+			if visited[*e.Coord] || e.block != nil && e.block.hidden {
+				continue
+			}
+			visited[*e.Coord] = true
+			v.castRay(e, v.endpoints[start:end], openings[:opening])
+		}
+
+		for ; len(closings) > 0 && closings[0].angle <= angle; closings = closings[1:] {
+			heap.Remove(&v.open, closings[0].occluder.heapIndex)
+		}
+		for _, event := range openings[:opening] {
+			heap.Push(&v.open, event.occluder)
+		}
+		openings = openings[opening:]
+
+		v.snapshots = append(v.snapshots, snapshot{angle: angle, closest: v.open.top()})
+		start = end
+	}
+
+	if len(v.Blocks) > 0 {
+		v.addArcs()
+	}
+
+	if v.Sensor.IsDirectional() {
+		v.clipToFieldOfView()
+	}
+	if v.Sensor.IsRangeLimited() {
+		v.clipToRange()
+	}
+}
+
+// castRay adds the polygon points for the ray from the center through e. Whatever is closest along
+// the ray is near the top of the heap or just opening, so occluders are popped off until they are
+// past the closest
+func (v *Viewshed) castRay(e *EndPoint, sameAngle []*EndPoint, opening []sweepEvent) {
+	// segments that share the endpoint and go the same way let the ray pass the corner, the ray
+	// doesn't hit them or the endpoint's own segment
+	var hasPassThrough bool
+	excluded := []*Segment{e.segment}
+	for _, other := range sameAngle {
+		if other.segment != nil && other.segment != e.segment && *other.Coord == *e.Coord && other.begin == e.begin {
+			excluded = append(excluded, other.segment)
+			hasPassThrough = true
+		}
+	}
+
+	// a tangent always passes through since the ray only grazes its block
+	hasPassThrough = hasPassThrough || e.block != nil
+
+	var popped, candidates []*occluder
+	bestDist := math.Inf(1)
+	isExcluded := func(o *occluder) bool {
+		return o.segment != nil && containsSegment(excluded, o.segment) || o.block != nil && o.block == e.block
+	}
+	for v.open.Len() > 0 {
+		o := v.open.top()
+		if isExcluded(o) {
+			popped = append(popped, heap.Pop(&v.open).(*occluder))
+			continue
+		}
+
+		dist := v.rayDistance(e, o)
+		if dist > bestDist+1e-9*(1+bestDist) {
+			break
+		}
+		popped = append(popped, heap.Pop(&v.open).(*occluder))
+		if !math.IsNaN(dist) && !math.IsInf(dist, 1) {
+			candidates = append(candidates, o)
+			bestDist = math.Min(bestDist, dist)
+		}
+	}
+	for _, o := range popped {
+		heap.Push(&v.open, o)
+	}
+	for _, event := range opening {
+		if dist := v.rayDistance(e, event.occluder); !isExcluded(event.occluder) && !math.IsNaN(dist) && !math.IsInf(dist, 1) {
+			candidates = append(candidates, event.occluder)
+		}
+	}
+
+	// anything this close is a tie, settle it the same way every time: segments before blocks,
+	// then in the order they were loaded
+	sort.Slice(candidates, func(i, j int) bool {
+		if (candidates[i].block == nil) != (candidates[j].block == nil) {
+			return candidates[i].block == nil
+		}
+		return candidates[i].order < candidates[j].order
+	})
+
+	closestIntersection := e.Coord //the intersection is the point if there isn't anything else
+	closestIntersectionDist := math.MaxFloat64
+	for _, o := range candidates {
+		var intersection *geom.Coord
+		var dist float64
+		if o.segment != nil {
+			intersection = lineIntersection(&v.Center, e.Coord, o.segment.P1.Coord, o.segment.P2.Coord)
+			dist = squareDistance(&v.Center, intersection)
+		} else {
+			intersection, dist, _ = o.block.rayIntersection(&v.Center, e.angle)
+		}
+		if dist < closestIntersectionDist {
+			closestIntersection = intersection
+			closestIntersectionDist = dist
+		}
+	}
+
+	if hasPassThrough && closestIntersectionDist > squareDistance(&v.Center, e.Coord) {
+		if e.begin {
+			v.ViewablePolygon = append(v.ViewablePolygon, closestIntersection)
+			v.ViewablePolygon = append(v.ViewablePolygon, e.Coord)
+		} else {
+			v.ViewablePolygon = append(v.ViewablePolygon, e.Coord)
+			v.ViewablePolygon = append(v.ViewablePolygon, closestIntersection)
+		}
+	} else {
+		v.ViewablePolygon = append(v.ViewablePolygon, closestIntersection)
+	}
+}
+
+func containsSegment(segments []*Segment, segment *Segment) bool {
+	for _, s := range segments {
+		if s == segment {
+			return true
+		}
+	}
+	return false
+}
+
+// rayDistance returns the square of the distance along the ray through e to o
+func (v *Viewshed) rayDistance(e *EndPoint, o *occluder) float64 {
+	if o.segment != nil {
+		return squareDistance(&v.Center, lineIntersection(&v.Center, e.Coord, o.segment.P1.Coord, o.segment.P2.Coord))
+	}
+	if _, dist, ok := o.block.rayIntersection(&v.Center, e.angle); ok {
+		return dist
+	}
+	return math.Inf(1)
+}
+
+// closestBlock returns the block a ray from the center at angle hits first, or nil if a segment is in
+// the way. Nothing opens or closes between two snapshots, so the one before angle has the answer
+func (v *Viewshed) closestBlock(angle float64) *Block {
+	i := sort.Search(len(v.snapshots), func(i int) bool { return v.snapshots[i].angle > angle }) - 1
+	if i < 0 || v.snapshots[i].closest == nil {
+		return nil
+	}
+	return v.snapshots[i].closest.block
+}
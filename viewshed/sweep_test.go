@@ -0,0 +1,134 @@
+package viewshed_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/brychanrobot/go-rrt-star/rrtstar"
+	"github.com/brychanrobot/go-rrt-star/viewshed"
+	"github.com/skelterjohn/geom"
+)
+
+// sweepTestMap is a generated map loaded into a viewshed, and what to check it against
+type sweepTestMap struct {
+	shed      *viewshed.Viewshed
+	obstacles rrtstar.ObstacleMap
+}
+
+func newSweepTestMap(width, height int, rects []*geom.Rect, polygons []rrtstar.Polygon, circles []*rrtstar.Circle, walls []*viewshed.Segment) sweepTestMap {
+	outlines := make([][]*geom.Coord, len(polygons))
+	for i, polygon := range polygons {
+		outlines[i] = polygon
+	}
+	blocks := make([]*viewshed.Block, len(circles))
+	for i, circle := range circles {
+		blocks[i] = viewshed.NewBlock(circle.X, circle.Y, circle.Radius)
+	}
+
+	shed := &viewshed.Viewshed{}
+	shed.LoadMap(float64(width), float64(height), 0, rects, outlines, blocks, walls)
+	return sweepTestMap{shed: shed, obstacles: rrtstar.NewVectorObstacleMap(width, height, rects, polygons, circles)}
+}
+
+func randomWalls(width, height, count int) []*viewshed.Segment {
+	var walls []*viewshed.Segment
+	for i := 0; i < count; i++ {
+		x, y := rand.Float64()*float64(width), rand.Float64()*float64(height)
+		angle, length := rand.Float64()*2*math.Pi, 20+rand.Float64()*100
+		walls = append(walls, &viewshed.Segment{
+			P1: &viewshed.EndPoint{Coord: &geom.Coord{X: x, Y: y}},
+			P2: &viewshed.EndPoint{Coord: &geom.Coord{X: x + length*math.Cos(angle), Y: y + length*math.Sin(angle)}}})
+	}
+	return walls
+}
+
+func TestSweepMatchesReference(t *testing.T) {
+	const width, height = 400, 300
+	for _, test := range []struct {
+		name     string
+		generate func() sweepTestMap
+	}{
+		{"rects", func() sweepTestMap {
+			rects, _ := rrtstar.GenerateObstacles(width, height, 8)
+			return newSweepTestMap(width, height, rects, nil, nil, nil)
+		}},
+		{"polygons", func() sweepTestMap {
+			polygons, _ := rrtstar.GeneratePolygonObstacles(width, height, 6)
+			return newSweepTestMap(width, height, nil, polygons, nil, nil)
+		}},
+		{"circles", func() sweepTestMap {
+			circles, _ := rrtstar.GenerateCircleObstacles(width, height, 6)
+			return newSweepTestMap(width, height, nil, nil, circles, nil)
+		}},
+		{"walls", func() sweepTestMap {
+			rects, _ := rrtstar.GenerateObstacles(width, height, 4)
+			return newSweepTestMap(width, height, rects, nil, nil, randomWalls(width, height, 6))
+		}},
+	} {
+		rand.Seed(1)
+		for trial := 0; trial < 20; trial++ {
+			m := test.generate()
+			for i := 0; i < 10; i++ {
+				center := geom.Coord{X: rand.Float64() * width, Y: rand.Float64() * height}
+				if m.obstacles.PointIntersects(center) {
+					continue
+				}
+				m.shed.Sensor = viewshed.Sensor{}
+				if i%3 == 1 {
+					m.shed.Sensor.MaxRange = 50 + rand.Float64()*200
+				}
+				if i%3 == 2 {
+					m.shed.Sensor = viewshed.Sensor{FieldOfView: math.Pi/4 + rand.Float64()*math.Pi, Heading: rand.Float64() * 2 * math.Pi}
+				}
+
+				m.shed.UpdateCenterLocation(center.X, center.Y)
+				m.shed.Sweep()
+				got := append([]*geom.Coord(nil), m.shed.ViewablePolygon...)
+				m.shed.ReferenceSweep()
+				want := m.shed.ViewablePolygon
+
+				if len(got) != len(want) {
+					t.Errorf("%s trial %d from %v: %d vertices, the reference has %d", test.name, trial, center, len(got), len(want))
+					continue
+				}
+				for j := range got {
+					if math.Abs(got[j].X-want[j].X) > 1e-6 || math.Abs(got[j].Y-want[j].Y) > 1e-6 {
+						t.Errorf("%s trial %d from %v: vertex %d is %v, the reference has %v", test.name, trial, center, j, *got[j], *want[j])
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// benchmarkMap is a grid of small rectangles, thousands of segments for a sweep to get through
+func benchmarkMap() *viewshed.Viewshed {
+	var rects []*geom.Rect
+	for x := 10.0; x < 1000; x += 33 {
+		for y := 10.0; y < 1000; y += 33 {
+			rects = append(rects, &geom.Rect{Min: geom.Coord{X: x, Y: y}, Max: geom.Coord{X: x + 11, Y: y + 7}})
+		}
+	}
+	shed := &viewshed.Viewshed{}
+	shed.LoadMap(1000, 1000, 0, rects, nil, nil, nil)
+	shed.UpdateCenterLocation(500, 500)
+	return shed
+}
+
+func BenchmarkSweep(b *testing.B) {
+	shed := benchmarkMap()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		shed.Sweep()
+	}
+}
+
+func BenchmarkReferenceSweep(b *testing.B) {
+	shed := benchmarkMap()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		shed.ReferenceSweep()
+	}
+}
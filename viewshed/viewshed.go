@@ -2,18 +2,23 @@ package viewshed
 
 import (
 	"math"
-	"sort"
 
 	"github.com/skelterjohn/geom"
 )
 
+// arcStep is the widest angle between the points that trace the visible edge of a block
+const arcStep = math.Pi / 90
+
 // Viewshed calculates and stores a viewshed given obstacle segments
 type Viewshed struct {
 	Segments        []*Segment
+	Blocks          []*Block
 	endpoints       []*EndPoint
 	Center          geom.Coord
 	ViewablePolygon []*geom.Coord
-	open            []*Segment
+	Sensor          Sensor
+	open            openSet
+	snapshots       []snapshot
 }
 
 func leftOf(s *Segment, p *geom.Coord) bool {
@@ -56,19 +61,31 @@ func (v *Viewshed) loadEdgeOfMap(width float64, height float64, margin float64)
 	v.addSegment(width-margin, margin, margin, margin)
 }
 
-// LoadMap loads a map from width, height, and a list of walls
-func (v *Viewshed) LoadMap(width float64, height float64, margin float64, blocks []*geom.Rect, walls []*Segment) {
+// LoadMap loads a map from width, height, rectangles, polygon outlines, round blocks, and a list of walls
+func (v *Viewshed) LoadMap(width float64, height float64, margin float64, rects []*geom.Rect, polygons [][]*geom.Coord, blocks []*Block, walls []*Segment) {
 	v.Segments = v.Segments[:0] //clear the slice
+	v.Blocks = v.Blocks[:0]
 	v.endpoints = v.endpoints[:0]
 
 	v.loadEdgeOfMap(width, height, margin)
+	for _, rect := range rects {
+		v.addSegmentsFromRectangle(rect)
+	}
+
+	for _, polygon := range polygons {
+		v.addSegmentsFromPolygon(polygon)
+	}
+
 	for _, block := range blocks {
-		v.addSegmentsFromRectangle(block)
+		v.Blocks = append(v.Blocks, block)
+		v.endpoints = append(v.endpoints, block.tangents[0], block.tangents[1])
 	}
 
 	for _, wall := range walls {
 		v.addSegment(wall.P1.X, wall.P1.Y, wall.P2.X, wall.P2.Y)
 	}
+
+	v.findCrossings()
 }
 
 func (v *Viewshed) addSegmentsFromRectangle(rect *geom.Rect) {
@@ -78,6 +95,14 @@ func (v *Viewshed) addSegmentsFromRectangle(rect *geom.Rect) {
 	v.addSegment(rect.Min.X, rect.Max.Y, rect.Min.X, rect.Min.Y)
 }
 
+// addSegmentsFromPolygon adds a segment for every side, the last vertex joins back up to the first
+func (v *Viewshed) addSegmentsFromPolygon(polygon []*geom.Coord) {
+	for i, p1 := range polygon {
+		p2 := polygon[(i+1)%len(polygon)]
+		v.addSegment(p1.X, p1.Y, p2.X, p2.Y)
+	}
+}
+
 func (v *Viewshed) addSegment(x1 float64, y1 float64, x2 float64, y2 float64) {
 	p1 := EndPoint{Coord: &geom.Coord{X: x1, Y: y1}, visualize: true}
 	p2 := EndPoint{Coord: &geom.Coord{X: x2, Y: y2}, visualize: false} //not sure why visualize is false
@@ -137,6 +162,19 @@ func Area2DPolygon(points []*geom.Coord) float64 {
 	return area / 2.0
 }
 
+// Area2DMultiPolygon computes the area of a region made up of polygons with holes, whichever way round
+// the outlines and holes go
+func Area2DMultiPolygon(region MultiPolygon) float64 {
+	area := 0.0
+	for _, polygon := range region {
+		area += math.Abs(Area2DPolygon(polygon.Outline))
+		for _, hole := range polygon.Holes {
+			area -= math.Abs(Area2DPolygon(hole))
+		}
+	}
+	return area
+}
+
 // UpdateCenterLocation updates the center and recalculates all angles
 func (v *Viewshed) UpdateCenterLocation(x float64, y float64) {
 	v.Center = geom.Coord{X: x, Y: y}
@@ -160,68 +198,41 @@ func (v *Viewshed) UpdateCenterLocation(x float64, y float64) {
 		segment.P1.begin = dAngle > 0
 		segment.P2.begin = !segment.P1.begin
 	}
-}
-
-func isWithinRange(target float64, a float64, b float64) bool {
-	if math.Abs(a-b) > math.Pi {
-		return a >= target && b >= target || a <= target && b <= target
-	}
-	return a <= target && target <= b || b <= target && target <= a
-}
 
-func isPassThrough(point *EndPoint, segment *Segment) bool {
-	if point.X == segment.P1.X && point.Y == segment.P1.Y {
-		return point.begin == segment.P1.begin
-	}
-	if point.X == segment.P2.X && point.Y == segment.P2.Y {
-		return point.begin == segment.P2.begin
+	for _, block := range v.Blocks {
+		block.updateTangents(v.Center)
 	}
-	return false
 }
 
-// Sweep computes a visibility polygon and returns all of the points
-func (v *Viewshed) Sweep() {
-	v.ViewablePolygon = v.ViewablePolygon[:0] // clear output
-	sort.Sort(ByAngleThenBegin(v.endpoints))
+// addArcs follows the edge of a block wherever it is the closest thing between two neighboring
+// points of the polygon, since the straight line between them would cut across it
+func (v *Viewshed) addArcs() {
+	polygon := make([]*geom.Coord, 0, len(v.ViewablePolygon))
+	for i, p1 := range v.ViewablePolygon {
+		polygon = append(polygon, p1)
 
-	for i := 0; i < len(v.endpoints); i += 2 {
-		e := v.endpoints[i]
-
-		var intersectedSegments []*Segment
-		var hasPassThrough bool
-		for _, segment := range v.Segments {
-			if segment != e.segment && isWithinRange(e.angle, segment.P1.angle, segment.P2.angle) {
-				isPassThrough := isPassThrough(e, segment)
-				if !isPassThrough {
-					intersectedSegments = append(intersectedSegments, segment)
-				}
-				hasPassThrough = hasPassThrough || isPassThrough
-
-			}
+		p2 := v.ViewablePolygon[(i+1)%len(v.ViewablePolygon)]
+		angle1 := math.Atan2(p1.Y-v.Center.Y, p1.X-v.Center.X)
+		span := math.Atan2(p2.Y-v.Center.Y, p2.X-v.Center.X) - angle1
+		if span <= -math.Pi {
+			span += 2 * math.Pi
+		}
+		// points on the same ray can come out a hair apart either way
+		if span < 1e-9 {
+			continue
 		}
 
-		closestIntersection := e.Coord //the intersection is the point if there isn't anything else
-		closestIntersectionDist := math.MaxFloat64
-
-		for _, segment := range intersectedSegments {
-			intersection := lineIntersection(&v.Center, e.Coord, segment.P1.Coord, segment.P2.Coord)
-			dist := squareDistance(&v.Center, intersection)
-			if dist < closestIntersectionDist {
-				closestIntersection = intersection
-				closestIntersectionDist = dist
-			}
+		block := v.closestBlock(angle1 + span/2)
+		if block == nil {
+			continue
 		}
 
-		if hasPassThrough && closestIntersectionDist > squareDistance(&v.Center, e.Coord) {
-			if e.begin {
-				v.ViewablePolygon = append(v.ViewablePolygon, closestIntersection)
-				v.ViewablePolygon = append(v.ViewablePolygon, e.Coord)
-			} else {
-				v.ViewablePolygon = append(v.ViewablePolygon, e.Coord)
-				v.ViewablePolygon = append(v.ViewablePolygon, closestIntersection)
+		steps := int(math.Ceil(span / arcStep))
+		for step := 1; step < steps; step++ {
+			if point, _, ok := block.rayIntersection(&v.Center, angle1+span*float64(step)/float64(steps)); ok {
+				polygon = append(polygon, point)
 			}
-		} else {
-			v.ViewablePolygon = append(v.ViewablePolygon, closestIntersection)
 		}
 	}
+	v.ViewablePolygon = polygon
 }